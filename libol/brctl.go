@@ -3,6 +3,8 @@ package libol
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 )
 
 type BrCtl struct {
@@ -44,3 +46,95 @@ func (b *BrCtl) Stp(on bool) error {
 	}
 	return nil
 }
+
+func (b *BrCtl) McastSnooping(on bool) error {
+	return writeBool(b.SysPath("multicast_snooping"), on)
+}
+
+// MdbEntry is a group learned on a port of the bridge.
+type MdbEntry struct {
+	Port  string
+	Group string
+	State string
+}
+
+// McastMdb returns the multicast database of the bridge.
+func (b *BrCtl) McastMdb() ([]MdbEntry, error) {
+	out, err := exec.Command("bridge", "mdb", "show", "dev", b.Name).CombinedOutput()
+	if err != nil {
+		return nil, NewErr("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return ParseMdb(string(out)), nil
+}
+
+// ParseMdb parses lines like 'dev br0 port eth1 grp 239.1.1.1 temp'.
+func ParseMdb(out string) []MdbEntry {
+	entries := make([]MdbEntry, 0, 32)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		entry := MdbEntry{}
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "port", "grp":
+				if i+1 < len(fields) {
+					if fields[i] == "port" {
+						entry.Port = fields[i+1]
+					} else {
+						entry.Group = fields[i+1]
+					}
+					i++
+				}
+			case "temp", "permanent":
+				entry.State = fields[i]
+			}
+		}
+		if entry.Port != "" && entry.Group != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func writeBool(file string, on bool) error {
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	defer fp.Close()
+	if err != nil {
+		return err
+	}
+
+	if on {
+		if _, err := fp.Write([]byte("1")); err != nil {
+			return err
+		}
+	} else {
+		if _, err := fp.Write([]byte("0")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type BrPort struct {
+	Name string
+	Path string
+}
+
+func NewBrPort(name string) (p *BrPort) {
+	p = &BrPort{
+		Name: name,
+	}
+	return
+}
+
+func (p *BrPort) SysPath(fun string) string {
+	if p.Path == "" {
+		p.Path = fmt.Sprintf("/sys/class/net/%s/brport", p.Name)
+	}
+
+	return fmt.Sprintf("%s/%s", p.Path, fun)
+}
+
+// Isolated ports can only communicate with non-isolated ports.
+func (p *BrPort) Isolated(on bool) error {
+	return writeBool(p.SysPath("isolated"), on)
+}
@@ -0,0 +1,17 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseMdb(t *testing.T) {
+	out := "dev br-default port eth1 grp 239.1.1.1 temp\n" +
+		"dev br-default port tap0 grp 239.1.1.2 permanent vid 1\n" +
+		"router ports on br-default: eth1\n"
+	entries := ParseMdb(out)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, MdbEntry{Port: "eth1", Group: "239.1.1.1", State: "temp"}, entries[0])
+	assert.Equal(t, "tap0", entries[1].Port)
+	assert.Equal(t, "permanent", entries[1].State)
+}
@@ -0,0 +1,187 @@
+package libol
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+const (
+	DhcpRequest = 1
+	DhcpReply   = 2
+)
+
+const (
+	DhcpDiscover = 1
+	DhcpOffer    = 2
+	DhcpRequire  = 3
+	DhcpDecline  = 4
+	DhcpAck      = 5
+	DhcpNak      = 6
+	DhcpRelease  = 7
+	DhcpInform   = 8
+)
+
+const (
+	DhcpOptPad       = 0
+	DhcpOptNetmask   = 1
+	DhcpOptRouter    = 3
+	DhcpOptDns       = 6
+	DhcpOptDomain    = 15
+	DhcpOptReqAddr   = 50
+	DhcpOptLeaseTime = 51
+	DhcpOptType      = 53
+	DhcpOptServerId  = 54
+	DhcpOptRoutes    = 121
+	DhcpOptEnd       = 255
+)
+
+const DhcpLen = 240
+
+var DhcpMagic = []byte{0x63, 0x82, 0x53, 0x63}
+
+type DhcpOption struct {
+	Code  uint8
+	Value []byte
+}
+
+type Dhcp struct {
+	Op      uint8
+	HType   uint8
+	HLen    uint8
+	Hops    uint8
+	Xid     uint32
+	Secs    uint16
+	Flags   uint16
+	CIAddr  []byte
+	YIAddr  []byte
+	SIAddr  []byte
+	GIAddr  []byte
+	CHAddr  []byte
+	Options []DhcpOption
+	Len     int
+}
+
+func NewDhcp() (d *Dhcp) {
+	d = &Dhcp{
+		HType:   1,
+		HLen:    6,
+		CIAddr:  make([]byte, 4),
+		YIAddr:  make([]byte, 4),
+		SIAddr:  make([]byte, 4),
+		GIAddr:  make([]byte, 4),
+		CHAddr:  make([]byte, 16),
+		Options: make([]DhcpOption, 0, 16),
+		Len:     DhcpLen,
+	}
+	return
+}
+
+func NewDhcpFromFrame(frame []byte) (d *Dhcp, err error) {
+	d = NewDhcp()
+	err = d.Decode(frame)
+	return
+}
+
+func (d *Dhcp) Decode(frame []byte) error {
+	if len(frame) < DhcpLen {
+		return NewErr("Dhcp.Decode: too small header: %d", len(frame))
+	}
+	if string(frame[236:240]) != string(DhcpMagic) {
+		return NewErr("Dhcp.Decode: invalid magic % x", frame[236:240])
+	}
+	d.Op = frame[0]
+	d.HType = frame[1]
+	d.HLen = frame[2]
+	d.Hops = frame[3]
+	d.Xid = binary.BigEndian.Uint32(frame[4:8])
+	d.Secs = binary.BigEndian.Uint16(frame[8:10])
+	d.Flags = binary.BigEndian.Uint16(frame[10:12])
+	copy(d.CIAddr[:4], frame[12:16])
+	copy(d.YIAddr[:4], frame[16:20])
+	copy(d.SIAddr[:4], frame[20:24])
+	copy(d.GIAddr[:4], frame[24:28])
+	copy(d.CHAddr[:16], frame[28:44])
+
+	p := DhcpLen
+	for p < len(frame) {
+		code := frame[p]
+		if code == DhcpOptEnd {
+			p++
+			break
+		}
+		if code == DhcpOptPad {
+			p++
+			continue
+		}
+		if p+2 > len(frame) || p+2+int(frame[p+1]) > len(frame) {
+			return NewErr("Dhcp.Decode: too small option %d", code)
+		}
+		size := int(frame[p+1])
+		value := make([]byte, size)
+		copy(value, frame[p+2:p+2+size])
+		d.Options = append(d.Options, DhcpOption{Code: code, Value: value})
+		p += 2 + size
+	}
+	d.Len = p
+
+	return nil
+}
+
+func (d *Dhcp) Encode() []byte {
+	buffer := make([]byte, DhcpLen, 576)
+
+	buffer[0] = d.Op
+	buffer[1] = d.HType
+	buffer[2] = d.HLen
+	buffer[3] = d.Hops
+	binary.BigEndian.PutUint32(buffer[4:8], d.Xid)
+	binary.BigEndian.PutUint16(buffer[8:10], d.Secs)
+	binary.BigEndian.PutUint16(buffer[10:12], d.Flags)
+	copy(buffer[12:16], d.CIAddr)
+	copy(buffer[16:20], d.YIAddr)
+	copy(buffer[20:24], d.SIAddr)
+	copy(buffer[24:28], d.GIAddr)
+	copy(buffer[28:44], d.CHAddr)
+	copy(buffer[236:240], DhcpMagic)
+	for _, opt := range d.Options {
+		buffer = append(buffer, opt.Code, uint8(len(opt.Value)))
+		buffer = append(buffer, opt.Value...)
+	}
+	buffer = append(buffer, DhcpOptEnd)
+	// pad to the minimum size of BOOTP.
+	for len(buffer) < 300 {
+		buffer = append(buffer, DhcpOptPad)
+	}
+	d.Len = len(buffer)
+
+	return buffer
+}
+
+func (d *Dhcp) Option(code uint8) []byte {
+	for _, opt := range d.Options {
+		if opt.Code == code {
+			return opt.Value
+		}
+	}
+	return nil
+}
+
+func (d *Dhcp) SetOption(code uint8, value []byte) {
+	d.Options = append(d.Options, DhcpOption{Code: code, Value: value})
+}
+
+// Type returns the DHCP message type, and zero if it's BOOTP.
+func (d *Dhcp) Type() uint8 {
+	if value := d.Option(DhcpOptType); len(value) == 1 {
+		return value[0]
+	}
+	return 0
+}
+
+func (d *Dhcp) HwAddr() net.HardwareAddr {
+	size := int(d.HLen)
+	if size > 16 {
+		size = 16
+	}
+	return net.HardwareAddr(d.CHAddr[:size])
+}
@@ -0,0 +1,179 @@
+package libol
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	DnsTypeA    = 1
+	DnsTypePtr  = 12
+	DnsTypeAAAA = 28
+	DnsClassIn  = 1
+)
+
+const (
+	DnsNoError  = 0
+	DnsServFail = 2
+	DnsNxDomain = 3
+	DnsRefused  = 5
+)
+
+const (
+	DnsFlagQR = 0x8000
+	DnsFlagAA = 0x0400
+	DnsFlagRD = 0x0100
+	DnsFlagRA = 0x0080
+)
+
+const DnsLen = 12
+
+type DnsQuestion struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+type DnsRecord struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	Ttl   uint32
+	Data  []byte
+}
+
+// Dns decodes the header and questions, and encodes answers only.
+type Dns struct {
+	Id        uint16
+	Flags     uint16
+	Questions []DnsQuestion
+	Answers   []DnsRecord
+	Len       int
+}
+
+func NewDns() (d *Dns) {
+	d = &Dns{
+		Questions: make([]DnsQuestion, 0, 1),
+		Answers:   make([]DnsRecord, 0, 4),
+		Len:       DnsLen,
+	}
+	return
+}
+
+func NewDnsFromFrame(frame []byte) (d *Dns, err error) {
+	d = NewDns()
+	err = d.Decode(frame)
+	return
+}
+
+func decodeDnsName(frame []byte, p int) (string, int, error) {
+	labels := make([]string, 0, 8)
+	next := -1
+	for jumps := 0; ; {
+		if p >= len(frame) {
+			return "", 0, NewErr("Dns.Decode: name out of frame")
+		}
+		size := int(frame[p])
+		if size == 0 {
+			p++
+			break
+		}
+		if size&0xc0 == 0xc0 { // compressed pointer.
+			if p+2 > len(frame) || jumps > 16 {
+				return "", 0, NewErr("Dns.Decode: invalid pointer")
+			}
+			if next < 0 {
+				next = p + 2
+			}
+			p = int(binary.BigEndian.Uint16(frame[p:p+2]) & 0x3fff)
+			jumps++
+			continue
+		}
+		if p+1+size > len(frame) {
+			return "", 0, NewErr("Dns.Decode: too small label")
+		}
+		labels = append(labels, string(frame[p+1:p+1+size]))
+		p += 1 + size
+	}
+	if next >= 0 {
+		p = next
+	}
+	return strings.Join(labels, ".") + ".", p, nil
+}
+
+func EncodeDnsName(name string) []byte {
+	buffer := make([]byte, 0, len(name)+2)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		buffer = append(buffer, uint8(len(label)))
+		buffer = append(buffer, label...)
+	}
+	return append(buffer, 0)
+}
+
+func (d *Dns) Decode(frame []byte) error {
+	if len(frame) < DnsLen {
+		return NewErr("Dns.Decode: too small header: %d", len(frame))
+	}
+	d.Id = binary.BigEndian.Uint16(frame[0:2])
+	d.Flags = binary.BigEndian.Uint16(frame[2:4])
+	count := int(binary.BigEndian.Uint16(frame[4:6]))
+
+	p := DnsLen
+	for i := 0; i < count; i++ {
+		name, n, err := decodeDnsName(frame, p)
+		if err != nil {
+			return err
+		}
+		if n+4 > len(frame) {
+			return NewErr("Dns.Decode: too small question")
+		}
+		d.Questions = append(d.Questions, DnsQuestion{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(frame[n : n+2]),
+			Class: binary.BigEndian.Uint16(frame[n+2 : n+4]),
+		})
+		p = n + 4
+	}
+	d.Len = p
+
+	return nil
+}
+
+func (d *Dns) Encode() []byte {
+	buffer := make([]byte, DnsLen, 512)
+
+	binary.BigEndian.PutUint16(buffer[0:2], d.Id)
+	binary.BigEndian.PutUint16(buffer[2:4], d.Flags)
+	binary.BigEndian.PutUint16(buffer[4:6], uint16(len(d.Questions)))
+	binary.BigEndian.PutUint16(buffer[6:8], uint16(len(d.Answers)))
+	for _, q := range d.Questions {
+		buffer = append(buffer, EncodeDnsName(q.Name)...)
+		buffer = append(buffer, uint8(q.Type>>8), uint8(q.Type))
+		buffer = append(buffer, uint8(q.Class>>8), uint8(q.Class))
+	}
+	for _, r := range d.Answers {
+		buffer = append(buffer, EncodeDnsName(r.Name)...)
+		fixed := make([]byte, 10)
+		binary.BigEndian.PutUint16(fixed[0:2], r.Type)
+		binary.BigEndian.PutUint16(fixed[2:4], r.Class)
+		binary.BigEndian.PutUint32(fixed[4:8], r.Ttl)
+		binary.BigEndian.PutUint16(fixed[8:10], uint16(len(r.Data)))
+		buffer = append(buffer, fixed...)
+		buffer = append(buffer, r.Data...)
+	}
+	d.Len = len(buffer)
+
+	return buffer
+}
+
+// Reply returns a response with the same id and questions.
+func (d *Dns) Reply(rcode uint16) *Dns {
+	r := NewDns()
+	r.Id = d.Id
+	r.Flags = DnsFlagQR | DnsFlagAA | DnsFlagRA | d.Flags&DnsFlagRD | rcode&0x0f
+	r.Questions = append(r.Questions, d.Questions...)
+	return r
+}
@@ -0,0 +1,196 @@
+package libol
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+const (
+	FlowEndIdle   = 1
+	FlowEndActive = 2
+	FlowEndOfFlow = 3
+)
+
+const flowTemplateId = 256
+
+// FlowRecord is an unidirectional flow of ipv4.
+type FlowRecord struct {
+	Source    net.IP
+	Dest      net.IP
+	Proto     uint8
+	SrcPort   uint16
+	DstPort   uint16
+	Bytes     uint64
+	Packets   uint64
+	Start     time.Time
+	End       time.Time
+	Ingress   uint32
+	EndReason uint8
+}
+
+// FlowEncoder encodes records into a message with the template, so a
+// collector started late also can decode it.
+type FlowEncoder interface {
+	Encode(records []FlowRecord, now time.Time) []byte
+	MaxRecords() int
+}
+
+type flowField struct {
+	id  uint16
+	len uint16
+}
+
+func flowTemplate(fields []flowField) []byte {
+	buffer := make([]byte, 4+len(fields)*4)
+	binary.BigEndian.PutUint16(buffer[0:2], flowTemplateId)
+	binary.BigEndian.PutUint16(buffer[2:4], uint16(len(fields)))
+	for i, f := range fields {
+		binary.BigEndian.PutUint16(buffer[4+i*4:6+i*4], f.id)
+		binary.BigEndian.PutUint16(buffer[6+i*4:8+i*4], f.len)
+	}
+	return buffer
+}
+
+// flowSet returns the set with header, and pads it to 4 bytes if pad.
+func flowSet(id uint16, body []byte, pad bool) []byte {
+	size := 4 + len(body)
+	if pad && size%4 != 0 {
+		size += 4 - size%4
+	}
+	buffer := make([]byte, size)
+	binary.BigEndian.PutUint16(buffer[0:2], id)
+	binary.BigEndian.PutUint16(buffer[2:4], uint16(size))
+	copy(buffer[4:], body)
+	return buffer
+}
+
+func putIp4(buffer []byte, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(buffer[0:4], ip4)
+	}
+}
+
+var ipFixFields = []flowField{
+	{8, 4},   // sourceIPv4Address
+	{12, 4},  // destinationIPv4Address
+	{4, 1},   // protocolIdentifier
+	{7, 2},   // sourceTransportPort
+	{11, 2},  // destinationTransportPort
+	{1, 8},   // octetDeltaCount
+	{2, 8},   // packetDeltaCount
+	{152, 8}, // flowStartMilliseconds
+	{153, 8}, // flowEndMilliseconds
+	{10, 4},  // ingressInterface
+	{136, 1}, // flowEndReason
+}
+
+const ipFixRecordLen = 50
+
+// IpFix encodes messages of ipfix by RFC7011.
+type IpFix struct {
+	Domain uint32
+	seq    uint32
+}
+
+func NewIpFix(domain uint32) *IpFix {
+	return &IpFix{Domain: domain}
+}
+
+func (e *IpFix) MaxRecords() int {
+	return 24
+}
+
+func (e *IpFix) Encode(records []FlowRecord, now time.Time) []byte {
+	data := make([]byte, 0, len(records)*ipFixRecordLen)
+	for _, r := range records {
+		buffer := make([]byte, ipFixRecordLen)
+		putIp4(buffer[0:4], r.Source)
+		putIp4(buffer[4:8], r.Dest)
+		buffer[8] = r.Proto
+		binary.BigEndian.PutUint16(buffer[9:11], r.SrcPort)
+		binary.BigEndian.PutUint16(buffer[11:13], r.DstPort)
+		binary.BigEndian.PutUint64(buffer[13:21], r.Bytes)
+		binary.BigEndian.PutUint64(buffer[21:29], r.Packets)
+		binary.BigEndian.PutUint64(buffer[29:37], uint64(r.Start.UnixNano()/int64(time.Millisecond)))
+		binary.BigEndian.PutUint64(buffer[37:45], uint64(r.End.UnixNano()/int64(time.Millisecond)))
+		binary.BigEndian.PutUint32(buffer[45:49], r.Ingress)
+		buffer[49] = r.EndReason
+		data = append(data, buffer...)
+	}
+	body := append(flowSet(2, flowTemplate(ipFixFields), false), flowSet(flowTemplateId, data, false)...)
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:2], 10)
+	binary.BigEndian.PutUint16(header[2:4], uint16(16+len(body)))
+	binary.BigEndian.PutUint32(header[4:8], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(header[8:12], e.seq)
+	binary.BigEndian.PutUint32(header[12:16], e.Domain)
+	e.seq += uint32(len(records))
+	return append(header, body...)
+}
+
+var netFlow9Fields = []flowField{
+	{8, 4},  // IPV4_SRC_ADDR
+	{12, 4}, // IPV4_DST_ADDR
+	{4, 1},  // PROTOCOL
+	{7, 2},  // L4_SRC_PORT
+	{11, 2}, // L4_DST_PORT
+	{1, 8},  // IN_BYTES
+	{2, 8},  // IN_PKTS
+	{22, 4}, // FIRST_SWITCHED
+	{21, 4}, // LAST_SWITCHED
+	{10, 4}, // INPUT_SNMP
+}
+
+const netFlow9RecordLen = 41
+
+// NetFlow9 encodes packets of netflow v9 by RFC3954, and times of flows
+// are milliseconds since boot.
+type NetFlow9 struct {
+	SourceId uint32
+	boot     time.Time
+	seq      uint32
+}
+
+func NewNetFlow9(sourceId uint32, boot time.Time) *NetFlow9 {
+	return &NetFlow9{SourceId: sourceId, boot: boot}
+}
+
+func (e *NetFlow9) MaxRecords() int {
+	return 30
+}
+
+func (e *NetFlow9) upTime(t time.Time) uint32 {
+	if t.Before(e.boot) {
+		return 0
+	}
+	return uint32(t.Sub(e.boot) / time.Millisecond)
+}
+
+func (e *NetFlow9) Encode(records []FlowRecord, now time.Time) []byte {
+	data := make([]byte, 0, len(records)*netFlow9RecordLen)
+	for _, r := range records {
+		buffer := make([]byte, netFlow9RecordLen)
+		putIp4(buffer[0:4], r.Source)
+		putIp4(buffer[4:8], r.Dest)
+		buffer[8] = r.Proto
+		binary.BigEndian.PutUint16(buffer[9:11], r.SrcPort)
+		binary.BigEndian.PutUint16(buffer[11:13], r.DstPort)
+		binary.BigEndian.PutUint64(buffer[13:21], r.Bytes)
+		binary.BigEndian.PutUint64(buffer[21:29], r.Packets)
+		binary.BigEndian.PutUint32(buffer[29:33], e.upTime(r.Start))
+		binary.BigEndian.PutUint32(buffer[33:37], e.upTime(r.End))
+		binary.BigEndian.PutUint32(buffer[37:41], r.Ingress)
+		data = append(data, buffer...)
+	}
+	body := append(flowSet(0, flowTemplate(netFlow9Fields), true), flowSet(flowTemplateId, data, true)...)
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:2], 9)
+	binary.BigEndian.PutUint16(header[2:4], uint16(1+len(records))) // template and records.
+	binary.BigEndian.PutUint32(header[4:8], e.upTime(now))
+	binary.BigEndian.PutUint32(header[8:12], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(header[12:16], e.seq)
+	binary.BigEndian.PutUint32(header[16:20], e.SourceId)
+	e.seq++
+	return append(header, body...)
+}
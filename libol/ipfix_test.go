@@ -0,0 +1,61 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func newFlowRecord() FlowRecord {
+	now := time.Now()
+	return FlowRecord{
+		Source:  net.ParseIP("192.168.1.10"),
+		Dest:    net.ParseIP("192.168.1.20"),
+		Proto:   IpTcp,
+		SrcPort: 40000,
+		DstPort: 22,
+		Bytes:   1500,
+		Packets: 3,
+		Start:   now.Add(-time.Second),
+		End:     now,
+		Ingress: 1,
+	}
+}
+
+func TestIpFix_Encode(t *testing.T) {
+	e := NewIpFix(7)
+	records := []FlowRecord{newFlowRecord(), newFlowRecord()}
+	data := e.Encode(records, time.Now())
+	assert.Equal(t, uint16(10), binary.BigEndian.Uint16(data[0:2]))
+	assert.Equal(t, len(data), int(binary.BigEndian.Uint16(data[2:4])))
+	assert.Equal(t, uint32(7), binary.BigEndian.Uint32(data[12:16]))
+	// template set.
+	assert.Equal(t, uint16(2), binary.BigEndian.Uint16(data[16:18]))
+	size := int(binary.BigEndian.Uint16(data[18:20]))
+	data = data[16+size:]
+	// data set.
+	assert.Equal(t, uint16(flowTemplateId), binary.BigEndian.Uint16(data[0:2]))
+	assert.Equal(t, 4+2*ipFixRecordLen, int(binary.BigEndian.Uint16(data[2:4])))
+	assert.Equal(t, []byte{192, 168, 1, 10}, data[4:8])
+	assert.Equal(t, uint64(1500), binary.BigEndian.Uint64(data[17:25]))
+
+	data = e.Encode(records, time.Now())
+	assert.Equal(t, uint32(2), binary.BigEndian.Uint32(data[8:12]))
+}
+
+func TestNetFlow9_Encode(t *testing.T) {
+	e := NewNetFlow9(7, time.Now().Add(-time.Minute))
+	data := e.Encode([]FlowRecord{newFlowRecord()}, time.Now())
+	assert.Equal(t, uint16(9), binary.BigEndian.Uint16(data[0:2]))
+	assert.Equal(t, uint16(2), binary.BigEndian.Uint16(data[2:4]))
+	assert.Equal(t, uint32(7), binary.BigEndian.Uint32(data[16:20]))
+	data = data[20:]
+	assert.Equal(t, uint16(0), binary.BigEndian.Uint16(data[0:2]))
+	size := int(binary.BigEndian.Uint16(data[2:4]))
+	data = data[size:]
+	assert.Equal(t, uint16(flowTemplateId), binary.BigEndian.Uint16(data[0:2]))
+	assert.Equal(t, 0, len(data)%4)
+	assert.Equal(t, uint64(3), binary.BigEndian.Uint64(data[4+21:4+29]))
+}
@@ -2,6 +2,7 @@ package libol
 
 import (
 	"fmt"
+	"net"
 	"os/exec"
 	"runtime"
 )
@@ -17,6 +18,78 @@ type FilterRule struct {
 	Output   string
 	Comment  string
 	Jump     string
+	Proto    string // tcp/udp.
+	DstPort  int    // only with tcp/udp.
+}
+
+var filterChains = map[string][]string{
+	"filter": {"INPUT", "FORWARD", "OUTPUT"},
+	"nat":    {"PREROUTING", "INPUT", "OUTPUT", "POSTROUTING"},
+	"mangle": {"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"},
+	"raw":    {"PREROUTING", "OUTPUT"},
+}
+
+func isAddr(addr string) bool {
+	if _, _, err := net.ParseCIDR(addr); err == nil {
+		return true
+	}
+	return net.ParseIP(addr) != nil
+}
+
+// Validate returns error if the rule is not supported by the switch.
+func (rule FilterRule) Validate() error {
+	chains, ok := filterChains[rule.Table]
+	if !ok {
+		return NewErr("invalid table %s", rule.Table)
+	}
+	found := false
+	for _, chain := range chains {
+		found = found || chain == rule.Chain
+	}
+	if !found {
+		return NewErr("invalid chain %s on %s", rule.Chain, rule.Table)
+	}
+	if rule.Source != "" && !isAddr(rule.Source) {
+		return NewErr("invalid source %s", rule.Source)
+	}
+	if rule.Dest != "" && !isAddr(rule.Dest) {
+		return NewErr("invalid destination %s", rule.Dest)
+	}
+	if rule.Proto != "" && rule.Proto != "tcp" && rule.Proto != "udp" {
+		return NewErr("invalid protocol %s", rule.Proto)
+	}
+	if rule.DstPort != 0 && (rule.Proto == "" || rule.DstPort < 0 || rule.DstPort > 65535) {
+		return NewErr("invalid destination port %d", rule.DstPort)
+	}
+	if rule.Table != "nat" && (rule.Jump == "MASQUERADE" || rule.Jump == "SNAT" || rule.Jump == "DNAT") {
+		return NewErr("%s only on nat", rule.Jump)
+	}
+	switch rule.Jump {
+	case "ACCEPT", "DROP", "RETURN", "LOG":
+	case "REJECT":
+		if rule.Table != "filter" {
+			return NewErr("REJECT only on filter")
+		}
+	case "TCPMSS":
+		if rule.Table != "mangle" && rule.Table != "filter" {
+			return NewErr("TCPMSS only on mangle or filter")
+		}
+	case "MASQUERADE":
+		if rule.Chain != "POSTROUTING" {
+			return NewErr("MASQUERADE only on nat POSTROUTING")
+		}
+	case "SNAT":
+		if rule.Chain != "POSTROUTING" && rule.Chain != "INPUT" || rule.ToSource == "" {
+			return NewErr("SNAT needs to-source on nat POSTROUTING or INPUT")
+		}
+	case "DNAT":
+		if rule.Chain != "PREROUTING" && rule.Chain != "OUTPUT" || rule.ToDest == "" {
+			return NewErr("DNAT needs to-destination on nat PREROUTING or OUTPUT")
+		}
+	default:
+		return NewErr("invalid jump %s", rule.Jump)
+	}
+	return nil
 }
 
 func IPTables(rule FilterRule, action string) (string, error) {
@@ -35,6 +108,12 @@ func IPTables(rule FilterRule, action string) (string, error) {
 		if rule.Output != "" {
 			args = append(args, "-o", rule.Output)
 		}
+		if rule.Proto != "" {
+			args = append(args, "-p", rule.Proto)
+		}
+		if rule.DstPort != 0 {
+			args = append(args, "--dport", fmt.Sprintf("%d", rule.DstPort))
+		}
 		if rule.Jump != "" {
 			args = append(args, "-j", rule.Jump)
 		}
@@ -50,3 +129,45 @@ func IPTables(rule FilterRule, action string) (string, error) {
 		return "", NewErr("iptables %s not support", runtime.GOOS)
 	}
 }
+
+func iptables(args ...string) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", NewErr("iptables %s not support", runtime.GOOS)
+	}
+	ret, err := exec.Command("/usr/sbin/iptables", args...).CombinedOutput()
+	return fmt.Sprintf("%v: %s", args, ret), err
+}
+
+// IPTablesNewChain creates the chain if not existed and flushes it, and
+// jumps to it from the builtin chain only once.
+func IPTablesNewChain(table, chain, from string) error {
+	if _, err := iptables("-t", table, "-N", chain); err != nil {
+		Debug("IPTablesNewChain: %s existed", chain)
+	}
+	if ret, err := iptables("-t", table, "-F", chain); err != nil {
+		return NewErr("%s", ret)
+	}
+	if _, err := iptables("-t", table, "-C", from, "-j", chain); err == nil {
+		return nil
+	}
+	if ret, err := iptables("-t", table, "-I", from, "-j", chain); err != nil {
+		return NewErr("%s", ret)
+	}
+	return nil
+}
+
+// IPTablesDelChain removes the jump from the builtin chain and the chain.
+func IPTablesDelChain(table, chain, from string) error {
+	for {
+		if _, err := iptables("-t", table, "-D", from, "-j", chain); err != nil {
+			break
+		}
+	}
+	if ret, err := iptables("-t", table, "-F", chain); err != nil {
+		return NewErr("%s", ret)
+	}
+	if ret, err := iptables("-t", table, "-X", chain); err != nil {
+		return NewErr("%s", ret)
+	}
+	return nil
+}
@@ -120,7 +120,7 @@ func IpRouteAdd(name, prefix, nexthop string, opts ...string) ([]byte, error) {
 	switch runtime.GOOS {
 	case "linux":
 		args := []string{
-			"route", "address", prefix, "via", nexthop,
+			"route", "add", prefix, "via", nexthop,
 		}
 		return exec.Command("/usr/sbin/ip", args...).CombinedOutput()
 	case "windows":
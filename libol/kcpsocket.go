@@ -3,6 +3,7 @@ package libol
 import (
 	"github.com/xtaci/kcp-go/v5"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -85,7 +86,7 @@ func (k *KcpServer) Accept() {
 			Error("KcpServer.Accept: %s", err)
 			return
 		}
-		k.sts.AcceptCount++
+		atomic.AddInt64(&k.sts.AcceptCount, 1)
 		conn.SetStreamMode(true)
 		conn.SetWriteDelay(false)
 		conn.SetACKNoDelay(false)
@@ -0,0 +1,33 @@
+package libol
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// ListenUDPOnDevice listens udp on addr, and only receives from the device.
+func ListenUDPOnDevice(device, addr string) (*net.UDPConn, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			if e := c.Control(func(fd uintptr) {
+				err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+				if err == nil {
+					err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+				}
+				if err == nil && device != "" {
+					err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device)
+				}
+			}); e != nil {
+				return e
+			}
+			return err
+		},
+	}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
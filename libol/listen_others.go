@@ -0,0 +1,11 @@
+// +build !linux
+
+package libol
+
+import (
+	"net"
+)
+
+func ListenUDPOnDevice(device, addr string) (*net.UDPConn, error) {
+	return nil, NewErr("ListenUDPOnDevice on %s not support", device)
+}
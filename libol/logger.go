@@ -2,11 +2,14 @@ package libol
 
 import (
 	"container/list"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,12 +26,26 @@ const (
 	FATAL = 99
 )
 
+const (
+	LogText   = "text"
+	LogJson   = "json"
+	LogLogFmt = "logfmt"
+)
+
 type Message struct {
 	Level   string `json:"level"`
 	Date    string `json:"date"`
 	Message string `json:"message"`
 }
 
+// LogLevels is levels of the logger, and a module is the prefix of
+// messages before '.', such as 'Switch' in 'Switch.Start'.
+type LogLevels struct {
+	Level   int            `json:"level"`
+	Format  string         `json:"format"`
+	Modules map[string]int `json:"modules"`
+}
+
 var levels = map[int]string{
 	PRINT: "PRINT",
 	LOG:   "LOG",
@@ -42,37 +59,143 @@ var levels = map[int]string{
 	FATAL: "FATAL",
 }
 
+// logSink receives lines of messages, such as syslog.
+type logSink interface {
+	Write(level int, line string)
+	Close() error
+}
+
 type _Log struct {
-	Level    int
+	level    int32
+	format   atomic.Value // string.
+	modules  atomic.Value // map[string]int, copied on write.
 	FileName string
 	FileLog  *log.Logger
+	StdLog   *log.Logger
+	rotate   *RotateFile
+	sink     logSink
 	Lock     sync.Mutex
 	Errors   *list.List
 }
 
+// moduleOf returns the module of the format.
+func moduleOf(format string) string {
+	if i := strings.IndexAny(format, ".: "); i > 0 {
+		return format[:i]
+	}
+	return ""
+}
+
+func (l *_Log) Level() int {
+	return int(atomic.LoadInt32(&l.level))
+}
+
+func (l *_Log) Format() string {
+	if format, ok := l.format.Load().(string); ok {
+		return format
+	}
+	return LogText
+}
+
+func (l *_Log) Modules() map[string]int {
+	if modules, ok := l.modules.Load().(map[string]int); ok {
+		return modules
+	}
+	return nil
+}
+
+// encode returns the line in the format, and fields are pairs of name and
+// value.
+func (l *_Log) encode(format, level, module, message string, fields []string) string {
+	switch format {
+	case LogJson:
+		values := make(map[string]string, 4+len(fields)/2)
+		values["time"] = time.Now().Format(time.RFC3339Nano)
+		values["level"] = level
+		values["module"] = module
+		values["msg"] = message
+		for i := 0; i+1 < len(fields); i += 2 {
+			values[fields[i]] = fields[i+1]
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	case LogLogFmt:
+		var buffer strings.Builder
+		buffer.WriteString("time=" + time.Now().Format(time.RFC3339Nano))
+		buffer.WriteString(" level=" + level)
+		buffer.WriteString(" module=" + logFmtValue(module))
+		buffer.WriteString(" msg=" + logFmtValue(message))
+		for i := 0; i+1 < len(fields); i += 2 {
+			buffer.WriteString(" " + fields[i] + "=" + logFmtValue(fields[i+1]))
+		}
+		return buffer.String()
+	}
+	line := level + " " + message
+	for i := 0; i+1 < len(fields); i += 2 {
+		line += " " + fields[i] + "=" + fields[i+1]
+	}
+	return line
+}
+
+func logFmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return fmt.Sprintf("%q", value)
+	}
+	return value
+}
+
 func (l *_Log) Write(level int, format string, v ...interface{}) {
+	l.write(level, nil, format, v...)
+}
+
+func (l *_Log) write(level int, fields []string, format string, v ...interface{}) {
+	stdLevel, fileLevel := l.Level(), INFO
+	module := ""
+	if modules := l.Modules(); len(modules) > 0 {
+		module = moduleOf(format)
+		if value, ok := modules[module]; ok {
+			stdLevel, fileLevel = value, value
+		}
+	}
+	if level < stdLevel && level < fileLevel && level < INFO {
+		return
+	}
 	str, ok := levels[level]
 	if !ok {
 		str = "NiL"
 	}
-	if level >= l.Level {
-		log.Printf(fmt.Sprintf("%s %s", str, format), v...)
+	logFormat := l.Format()
+	if module == "" && logFormat != LogText {
+		module = moduleOf(format)
+	}
+	message := fmt.Sprintf(format, v...)
+	line := l.encode(logFormat, str, module, message, fields)
+	if level >= stdLevel {
+		if logFormat == LogText {
+			log.Print(line)
+		} else {
+			l.StdLog.Print(line)
+		}
+	}
+	if level >= fileLevel {
+		if l.FileLog != nil {
+			l.FileLog.Print(line)
+		}
+		if l.sink != nil {
+			l.sink.Write(level, line)
+		}
 	}
 	if level >= INFO {
-		l.Save(str, format, v...)
+		l.Save(str, message)
 	}
 }
 
-func (l *_Log) Save(level string, format string, v ...interface{}) {
-	m := fmt.Sprintf(format, v...)
-	if l.FileLog != nil {
-		l.FileLog.Println(level + " " + m)
-	}
-
+// Save keeps the latest messages to show.
+func (l *_Log) Save(level string, message string) {
 	l.Lock.Lock()
 	defer l.Lock.Unlock()
 	if l.Errors.Len() >= 1024 {
-		if e := l.Errors.Back(); e != nil {
+		if e := l.Errors.Front(); e != nil {
 			l.Errors.Remove(e)
 		}
 	}
@@ -81,7 +204,7 @@ func (l *_Log) Save(level string, format string, v ...interface{}) {
 	ele := &Message{
 		Level:   level,
 		Date:    fmt.Sprintf("%d/%02d/%02d %02d:%02d:%02d", yy, mm, dd, hh, mn, se),
-		Message: m,
+		Message: message,
 	}
 	l.Errors.PushBack(ele)
 }
@@ -100,11 +223,50 @@ func (l *_Log) List() <-chan *Message {
 }
 
 var Logger = _Log{
-	Level:    INFO,
+	level:    INFO,
 	FileName: ".log.error",
+	StdLog:   log.New(os.Stderr, "", 0),
 	Errors:   list.New(),
 }
 
+// Entry writes messages with fields, such as client, network and uuid.
+type Entry struct {
+	fields []string
+}
+
+// With returns an entry with fields, which are pairs of name and value.
+func With(fields ...string) *Entry {
+	return &Entry{fields: fields}
+}
+
+func (e *Entry) With(fields ...string) *Entry {
+	return &Entry{fields: append(append([]string{}, e.fields...), fields...)}
+}
+
+func (e *Entry) Log(format string, v ...interface{}) {
+	Logger.write(LOG, e.fields, format, v...)
+}
+
+func (e *Entry) Debug(format string, v ...interface{}) {
+	Logger.write(DEBUG, e.fields, format, v...)
+}
+
+func (e *Entry) Cmd(format string, v ...interface{}) {
+	Logger.write(CMD, e.fields, format, v...)
+}
+
+func (e *Entry) Info(format string, v ...interface{}) {
+	Logger.write(INFO, e.fields, format, v...)
+}
+
+func (e *Entry) Warn(format string, v ...interface{}) {
+	Logger.write(WARN, e.fields, format, v...)
+}
+
+func (e *Entry) Error(format string, v ...interface{}) {
+	Logger.write(ERROR, e.fields, format, v...)
+}
+
 func Print(format string, v ...interface{}) {
 	Logger.Write(PRINT, format, v...)
 }
@@ -149,9 +311,10 @@ func Init(file string, level int) {
 	SetLog(level)
 	Logger.FileName = file
 	if Logger.FileName != "" {
-		logFile, err := os.Create(Logger.FileName)
+		rotate, err := NewRotateFile(Logger.FileName)
 		if err == nil {
-			Logger.FileLog = log.New(logFile, "", log.LstdFlags)
+			Logger.rotate = rotate
+			Logger.FileLog = log.New(rotate, "", log.LstdFlags)
 		} else {
 			Warn("Logger.Init: %s", err)
 		}
@@ -159,11 +322,101 @@ func Init(file string, level int) {
 }
 
 func SetLog(level int) {
-	Logger.Level = level
+	atomic.StoreInt32(&Logger.level, int32(level))
+}
+
+// SetFormat changes the format of messages to text, json or logfmt.
+func SetFormat(format string) error {
+	switch format {
+	case "", LogText:
+		format = LogText
+	case LogJson, LogLogFmt:
+	default:
+		return NewErr("invalid format %s", format)
+	}
+	Logger.format.Store(format)
+	if Logger.FileLog != nil {
+		if format == LogText {
+			Logger.FileLog.SetFlags(log.LstdFlags)
+		} else {
+			Logger.FileLog.SetFlags(0)
+		}
+	}
+	return nil
+}
+
+// SetRotate rotates the file if larger than size or older than interval,
+// and keeps backups at most and not older than age.
+func SetRotate(size int64, interval time.Duration, backups int, age time.Duration) {
+	if Logger.rotate != nil {
+		Logger.rotate.SetLimit(size, interval, backups, age)
+	}
+}
+
+// SetModule changes the level of the module, and removes it if level is
+// negative.
+func SetModule(name string, level int) {
+	Logger.Lock.Lock()
+	defer Logger.Lock.Unlock()
+
+	older := Logger.Modules()
+	modules := make(map[string]int, len(older)+1)
+	for k, v := range older {
+		modules[k] = v
+	}
+	if level < 0 {
+		delete(modules, name)
+	} else {
+		modules[name] = level
+	}
+	Logger.modules.Store(modules)
+}
+
+func GetLevels() LogLevels {
+	levels := LogLevels{
+		Level:   Logger.Level(),
+		Format:  Logger.Format(),
+		Modules: make(map[string]int, 32),
+	}
+	for k, v := range Logger.Modules() {
+		levels.Modules[k] = v
+	}
+	return levels
+}
+
+// SetSyslog sends messages to syslog at addr like udp://host:514, or to
+// the local syslog if addr is 'local', which also goes to journald.
+func SetSyslog(addr string) error {
+	if Logger.sink != nil {
+		_ = Logger.sink.Close()
+		Logger.sink = nil
+	}
+	if addr == "" {
+		return nil
+	}
+	network, address := "", ""
+	if addr != "local" {
+		values := strings.SplitN(addr, "://", 2)
+		if len(values) != 2 {
+			return NewErr("invalid syslog %s", addr)
+		}
+		network, address = values[0], values[1]
+	}
+	sink, err := newSyslog(network, address)
+	if err != nil {
+		return err
+	}
+	Logger.sink = sink
+	return nil
 }
 
 func Close() {
-	//TODO
+	if Logger.sink != nil {
+		_ = Logger.sink.Close()
+	}
+	if Logger.rotate != nil {
+		_ = Logger.rotate.Close()
+	}
 }
 
 func Catch(name string) {
@@ -0,0 +1,44 @@
+package libol
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotateFile_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "openlan.log")
+	r, err := NewRotateFile(file)
+	assert.Nil(t, err)
+	r.SetLimit(16, 0, 2, 0)
+	for i := 0; i < 5; i++ {
+		_, err := r.Write([]byte("0123456789abcdef"))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, r.Close())
+	backups, _ := filepath.Glob(file + ".*")
+	assert.Equal(t, 2, len(backups))
+	data, _ := ioutil.ReadFile(file)
+	assert.Equal(t, 16, len(data))
+}
+
+func TestLog_Encode(t *testing.T) {
+	line := Logger.encode(LogJson, "INFO", "Switch", "Switch.Start", []string{"network", "default"})
+	values := make(map[string]string)
+	assert.Nil(t, json.Unmarshal([]byte(line), &values))
+	assert.Equal(t, "Switch", values["module"])
+	assert.Equal(t, "default", values["network"])
+
+	line = Logger.encode(LogLogFmt, "INFO", "Switch", "Switch.Start: on", []string{"uuid", "a b"})
+	assert.True(t, strings.Contains(line, ` msg="Switch.Start: on"`))
+	assert.True(t, strings.Contains(line, ` uuid="a b"`))
+	assert.Equal(t, "Switch", moduleOf("Switch.Start: %s"))
+}
@@ -0,0 +1,117 @@
+package libol
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotateFile is a file renamed with time suffix if larger than size or
+// older than interval, and backups are removed if more than backups or
+// older than age. Zero means no limit.
+type RotateFile struct {
+	lock     sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	opened   time.Time
+	maxSize  int64
+	interval time.Duration
+	backups  int
+	maxAge   time.Duration
+}
+
+func NewRotateFile(path string) (*RotateFile, error) {
+	r := &RotateFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotateFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+func (r *RotateFile) SetLimit(size int64, interval time.Duration, backups int, age time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.maxSize, r.interval, r.backups, r.maxAge = size, interval, backups, age
+}
+
+func (r *RotateFile) needRotate(size int) bool {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(size) > r.maxSize {
+		return true
+	}
+	return r.interval > 0 && time.Since(r.opened) >= r.interval
+}
+
+func (r *RotateFile) rotate() error {
+	_ = r.file.Close()
+	backup := fmt.Sprintf("%s.%s", r.path, time.Now().Format("20060102-150405"))
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.%s.%d", r.path, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(r.path, backup); err == nil {
+		r.prune()
+	}
+	return r.open()
+}
+
+// prune removes backups more than backups or older than age.
+func (r *RotateFile) prune() {
+	files, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	for i, file := range files {
+		remove := r.backups > 0 && i < len(files)-r.backups
+		if !remove && r.maxAge > 0 {
+			if info, err := os.Stat(file); err == nil {
+				remove = time.Since(info.ModTime()) > r.maxAge
+			}
+		}
+		if remove {
+			_ = os.Remove(file)
+		}
+	}
+}
+
+func (r *RotateFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.needRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotateFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
@@ -0,0 +1,56 @@
+package libol
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	MetricCounter = "counter"
+	MetricGauge   = "gauge"
+)
+
+var metricEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics writes samples by the text format of prometheus, and samples of
+// a metric should be added together.
+type Metrics struct {
+	buffer bytes.Buffer
+	named  map[string]bool
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		named: make(map[string]bool, 64),
+	}
+}
+
+// Add appends a sample of the metric, and labels are pairs of name and value.
+func (m *Metrics) Add(name, typ, help string, value interface{}, labels ...string) {
+	if !m.named[name] {
+		m.named[name] = true
+		m.buffer.WriteString(fmt.Sprintf("# HELP %s %s\n", name, help))
+		m.buffer.WriteString(fmt.Sprintf("# TYPE %s %s\n", name, typ))
+	}
+	m.buffer.WriteString(name)
+	if len(labels) >= 2 {
+		m.buffer.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buffer.WriteString(",")
+			}
+			m.buffer.WriteString(fmt.Sprintf(`%s="%s"`, labels[i], metricEscaper.Replace(labels[i+1])))
+		}
+		m.buffer.WriteString("}")
+	}
+	m.buffer.WriteString(fmt.Sprintf(" %v\n", value))
+}
+
+func (m *Metrics) Bytes() []byte {
+	return m.buffer.Bytes()
+}
+
+func (m *Metrics) String() string {
+	return m.buffer.String()
+}
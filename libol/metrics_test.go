@@ -0,0 +1,21 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMetrics_Add(t *testing.T) {
+	m := NewMetrics()
+	m.Add("a_total", MetricCounter, "A.", 1, "name", "q\"1")
+	m.Add("a_total", MetricCounter, "A.", 2, "name", "2")
+	m.Add("b", MetricGauge, "B.", 3)
+	expected := "# HELP a_total A.\n" +
+		"# TYPE a_total counter\n" +
+		"a_total{name=\"q\\\"1\"} 1\n" +
+		"a_total{name=\"2\"} 2\n" +
+		"# HELP b B.\n" +
+		"# TYPE b gauge\n" +
+		"b 3\n"
+	assert.Equal(t, expected, m.String())
+}
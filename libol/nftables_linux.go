@@ -0,0 +1,434 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/vishvananda/netlink/nl"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// nf_tables netlink messages and attributes from linux/netfilter/nf_tables.h.
+const (
+	nfnlSubsysNfTables = 10
+	nfnlMsgBatchBegin  = 0x10
+	nfnlMsgBatchEnd    = 0x11
+	nftMsgNewTable     = 0
+	nftMsgDelTable     = 2
+	nftMsgNewChain     = 3
+	nftMsgNewRule      = 6
+	nlaFNested         = 0x8000
+	nfProtoIPv4        = 2
+	netlinkNetfilter   = 12
+
+	nftaTableName       = 1
+	nftaChainTable      = 1
+	nftaChainName       = 3
+	nftaChainHook       = 4
+	nftaChainType       = 7
+	nftaHookNum         = 1
+	nftaHookPriority    = 2
+	nftaRuleTable       = 1
+	nftaRuleChain       = 2
+	nftaRuleExpressions = 4
+	nftaListElem        = 1
+	nftaExprName        = 1
+	nftaExprData        = 2
+	nftaDataValue       = 1
+	nftaDataVerdict     = 2
+	nftaVerdictCode     = 1
+
+	nftRegVerdict       = 0
+	nftReg1             = 1
+	nftReg2             = 2
+	nftPayloadNet       = 1
+	nftPayloadTransport = 2
+	nftMetaIif          = 6
+	nftMetaOif          = 7
+	nftMetaL4Proto      = 16
+	nftCmpEq            = 0
+	nftNatSnat          = 0
+	nftNatDnat          = 1
+	nftReturn           = -5
+	nfDrop              = 0
+	nfAccept            = 1
+)
+
+type nfAttr struct {
+	typ      uint16
+	data     []byte
+	children []*nfAttr
+}
+
+func (a *nfAttr) encode() []byte {
+	payload := a.data
+	for _, c := range a.children {
+		payload = append(payload, c.encode()...)
+	}
+	size := 4 + len(payload)
+	buffer := make([]byte, (size+3) & ^3)
+	nl.NativeEndian().PutUint16(buffer[0:2], uint16(size))
+	nl.NativeEndian().PutUint16(buffer[2:4], a.typ)
+	copy(buffer[4:], payload)
+	return buffer
+}
+
+func nfString(typ uint16, value string) *nfAttr {
+	return &nfAttr{typ: typ, data: append([]byte(value), 0)}
+}
+
+func nfU32(typ uint16, value uint32) *nfAttr {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return &nfAttr{typ: typ, data: data}
+}
+
+func nfBytes(typ uint16, value []byte) *nfAttr {
+	return &nfAttr{typ: typ, data: value}
+}
+
+func nfNest(typ uint16, children ...*nfAttr) *nfAttr {
+	return &nfAttr{typ: typ | nlaFNested, children: children}
+}
+
+func nfExpr(name string, data ...*nfAttr) *nfAttr {
+	if len(data) == 0 {
+		return nfNest(nftaListElem, nfString(nftaExprName, name))
+	}
+	return nfNest(nftaListElem, nfString(nftaExprName, name), nfNest(nftaExprData, data...))
+}
+
+func nfCmpEq(value []byte) *nfAttr {
+	return nfExpr("cmp",
+		nfU32(1, nftReg1), // sreg
+		nfU32(2, nftCmpEq),
+		nfNest(3, nfBytes(nftaDataValue, value)))
+}
+
+// nfMatchAddr returns expressions matching ip address at offset of header.
+func nfMatchAddr(offset uint32, addr string) ([]*nfAttr, error) {
+	if !strings.Contains(addr, "/") {
+		addr += "/32"
+	}
+	_, prefix, err := net.ParseCIDR(addr)
+	if err != nil || prefix.IP.To4() == nil {
+		return nil, NewErr("invalid address %s", addr)
+	}
+	exprs := []*nfAttr{
+		nfExpr("payload",
+			nfU32(1, nftReg1), // dreg
+			nfU32(2, nftPayloadNet),
+			nfU32(3, offset),
+			nfU32(4, 4)),
+	}
+	if ones, _ := prefix.Mask.Size(); ones != 32 {
+		exprs = append(exprs, nfExpr("bitwise",
+			nfU32(1, nftReg1), // sreg
+			nfU32(2, nftReg1), // dreg
+			nfU32(3, 4),
+			nfNest(4, nfBytes(nftaDataValue, prefix.Mask)),
+			nfNest(5, nfBytes(nftaDataValue, make([]byte, 4)))))
+	}
+	return append(exprs, nfCmpEq(prefix.IP.To4())), nil
+}
+
+func nfMatchIf(key uint32, name string) []*nfAttr {
+	return []*nfAttr{
+		nfExpr("meta", nfU32(1, nftReg1), nfU32(2, key)),
+		nfCmpEq(append([]byte(name), 0)),
+	}
+}
+
+func nfMatchProto(proto string, port int) ([]*nfAttr, error) {
+	var value uint8
+	switch proto {
+	case "tcp":
+		value = IpTcp
+	case "udp":
+		value = IpUdp
+	default:
+		return nil, NewErr("invalid protocol %s", proto)
+	}
+	exprs := []*nfAttr{
+		nfExpr("meta", nfU32(1, nftReg1), nfU32(2, nftMetaL4Proto)),
+		nfCmpEq([]byte{value}),
+	}
+	if port != 0 {
+		data := make([]byte, 2)
+		binary.BigEndian.PutUint16(data, uint16(port))
+		exprs = append(exprs,
+			nfExpr("payload",
+				nfU32(1, nftReg1), // dreg
+				nfU32(2, nftPayloadTransport),
+				nfU32(3, 2),
+				nfU32(4, 2)),
+			nfCmpEq(data))
+	}
+	return exprs, nil
+}
+
+func nfVerdict(code int32) *nfAttr {
+	return nfExpr("immediate",
+		nfU32(1, nftRegVerdict),
+		nfNest(2, nfNest(nftaDataVerdict, nfU32(nftaVerdictCode, uint32(code)))))
+}
+
+func nfNat(typ uint32, to string) ([]*nfAttr, error) {
+	// address with an optional port, such as 192.168.1.1 or 192.168.1.1:80.
+	values := strings.SplitN(to, ":", 2)
+	ip := net.ParseIP(strings.SplitN(values[0], "-", 2)[0]).To4()
+	if ip == nil {
+		return nil, NewErr("invalid nat address %s", to)
+	}
+	exprs := []*nfAttr{
+		nfExpr("immediate",
+			nfU32(1, nftReg1),
+			nfNest(2, nfBytes(nftaDataValue, ip))),
+	}
+	attrs := []*nfAttr{
+		nfU32(1, typ),
+		nfU32(2, nfProtoIPv4),
+		nfU32(3, nftReg1), // reg addr min
+	}
+	if len(values) == 2 {
+		port, err := strconv.ParseUint(strings.SplitN(values[1], "-", 2)[0], 10, 16)
+		if err != nil {
+			return nil, NewErr("invalid nat port %s", to)
+		}
+		data := make([]byte, 2)
+		binary.BigEndian.PutUint16(data, uint16(port))
+		exprs = append(exprs, nfExpr("immediate",
+			nfU32(1, nftReg2),
+			nfNest(2, nfBytes(nftaDataValue, data))))
+		attrs = append(attrs, nfU32(5, nftReg2)) // reg proto min
+	}
+	return append(exprs, nfExpr("nat", attrs...)), nil
+}
+
+// nfExprs returns expressions of the rule.
+func nfExprs(rule FilterRule) ([]*nfAttr, error) {
+	exprs := make([]*nfAttr, 0, 16)
+	if rule.Input != "" {
+		exprs = append(exprs, nfMatchIf(nftMetaIif, rule.Input)...)
+	}
+	if rule.Output != "" {
+		exprs = append(exprs, nfMatchIf(nftMetaOif, rule.Output)...)
+	}
+	if rule.Source != "" {
+		match, err := nfMatchAddr(12, rule.Source)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, match...)
+	}
+	if rule.Dest != "" {
+		match, err := nfMatchAddr(16, rule.Dest)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, match...)
+	}
+	if rule.Proto != "" {
+		match, err := nfMatchProto(rule.Proto, rule.DstPort)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, match...)
+	}
+	switch rule.Jump {
+	case "ACCEPT":
+		exprs = append(exprs, nfVerdict(nfAccept))
+	case "DROP":
+		exprs = append(exprs, nfVerdict(nfDrop))
+	case "RETURN":
+		exprs = append(exprs, nfVerdict(nftReturn))
+	case "MASQUERADE":
+		exprs = append(exprs, nfExpr("masq"))
+	case "SNAT":
+		nat, err := nfNat(nftNatSnat, rule.ToSource)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, nat...)
+	case "DNAT":
+		nat, err := nfNat(nftNatDnat, rule.ToDest)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, nat...)
+	default:
+		return nil, NewErr("not support jump %s", rule.Jump)
+	}
+	return exprs, nil
+}
+
+type nfChain struct {
+	name     string
+	typ      string
+	hook     uint32
+	priority int32
+}
+
+var nfChains = map[string]nfChain{
+	"filter/INPUT":    {"input", "filter", 1, 0},
+	"filter/FORWARD":  {"forward", "filter", 2, 0},
+	"filter/OUTPUT":   {"output", "filter", 3, 0},
+	"nat/PREROUTING":  {"prerouting", "nat", 0, -100},
+	"nat/INPUT":       {"nat-input", "nat", 1, 100},
+	"nat/OUTPUT":      {"nat-output", "nat", 3, -100},
+	"nat/POSTROUTING": {"postrouting", "nat", 4, 100},
+}
+
+// NfTable owns a table of nftables, and applies the whole ruleset by a
+// netlink batch atomically.
+type NfTable struct {
+	lock Locker
+	name string
+	seq  uint32
+	msgs [][]byte
+}
+
+func NewNfTable(name string) *NfTable {
+	return &NfTable{name: name}
+}
+
+func (t *NfTable) add(typ uint16, flags uint16, attrs ...*nfAttr) {
+	t.seq++
+	var payload []byte
+	for _, a := range attrs {
+		payload = append(payload, a.encode()...)
+	}
+	size := syscall.NLMSG_HDRLEN + 4 + len(payload)
+	buffer := make([]byte, size)
+	native := nl.NativeEndian()
+	native.PutUint32(buffer[0:4], uint32(size))
+	native.PutUint16(buffer[4:6], typ)
+	native.PutUint16(buffer[6:8], flags)
+	native.PutUint32(buffer[8:12], t.seq)
+	buffer[16] = nfProtoIPv4
+	if typ == nfnlMsgBatchBegin || typ == nfnlMsgBatchEnd {
+		buffer[16] = syscall.AF_UNSPEC
+		binary.BigEndian.PutUint16(buffer[18:20], nfnlSubsysNfTables)
+	}
+	copy(buffer[20:], payload)
+	t.msgs = append(t.msgs, buffer)
+}
+
+func (t *NfTable) addMsg(msg uint16, flags uint16, attrs ...*nfAttr) {
+	typ := uint16(nfnlSubsysNfTables<<8) | msg
+	t.add(typ, syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|flags, attrs...)
+}
+
+// reset adds messages to create the table if not exists and delete it,
+// so the table is empty in this batch whatever existed before.
+func (t *NfTable) reset() {
+	t.addMsg(nftMsgNewTable, syscall.NLM_F_CREATE, nfString(nftaTableName, t.name))
+	t.addMsg(nftMsgDelTable, 0, nfString(nftaTableName, t.name))
+}
+
+func (t *NfTable) commit() error {
+	defer func() { t.msgs = nil }()
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, netlinkNetfilter)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+	tv := syscall.Timeval{Sec: 2}
+	_ = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+
+	var batch []byte
+	for _, m := range t.msgs {
+		batch = append(batch, m...)
+	}
+	if err := syscall.Sendto(fd, batch, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+	// wait ack for each message except begin and end.
+	waits := len(t.msgs) - 2
+	buffer := make([]byte, 64*1024)
+	for waits > 0 {
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Type != syscall.NLMSG_ERROR || len(m.Data) < 4 {
+				continue
+			}
+			if errno := int32(nl.NativeEndian().Uint32(m.Data[0:4])); errno != 0 {
+				return NewErr("nftables seq %d: %s", m.Header.Seq, syscall.Errno(-errno))
+			}
+			waits--
+		}
+	}
+	return nil
+}
+
+// batch adds messages to replace all rules in the table, and returns rules
+// not supported by nftables.
+func (t *NfTable) batch(rules []FilterRule) []FilterRule {
+	skipped := make([]FilterRule, 0, 4)
+	t.add(nfnlMsgBatchBegin, syscall.NLM_F_REQUEST)
+	t.reset()
+	t.addMsg(nftMsgNewTable, syscall.NLM_F_CREATE, nfString(nftaTableName, t.name))
+	created := make(map[string]bool, 8)
+	for _, rule := range rules {
+		chain, ok := nfChains[rule.Table+"/"+rule.Chain]
+		if !ok {
+			skipped = append(skipped, rule)
+			continue
+		}
+		exprs, err := nfExprs(rule)
+		if err != nil {
+			Warn("NfTable.Apply: %s", err)
+			skipped = append(skipped, rule)
+			continue
+		}
+		if !created[chain.name] {
+			created[chain.name] = true
+			t.addMsg(nftMsgNewChain, syscall.NLM_F_CREATE,
+				nfString(nftaChainTable, t.name),
+				nfString(nftaChainName, chain.name),
+				nfNest(nftaChainHook,
+					nfU32(nftaHookNum, chain.hook),
+					nfU32(nftaHookPriority, uint32(chain.priority))),
+				nfString(nftaChainType, chain.typ))
+		}
+		t.addMsg(nftMsgNewRule, syscall.NLM_F_CREATE|syscall.NLM_F_APPEND,
+			nfString(nftaRuleTable, t.name),
+			nfString(nftaRuleChain, chain.name),
+			nfNest(nftaRuleExpressions, exprs...))
+	}
+	t.add(nfnlMsgBatchEnd, syscall.NLM_F_REQUEST)
+	return skipped
+}
+
+// Apply replaces all rules in the table by rules atomically, and returns
+// rules not supported by nftables.
+func (t *NfTable) Apply(rules []FilterRule) ([]FilterRule, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	skipped := t.batch(rules)
+	return skipped, t.commit()
+}
+
+// Clear removes the table and all rules in it.
+func (t *NfTable) Clear() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.add(nfnlMsgBatchBegin, syscall.NLM_F_REQUEST)
+	t.reset()
+	t.add(nfnlMsgBatchEnd, syscall.NLM_F_REQUEST)
+	return t.commit()
+}
@@ -0,0 +1,143 @@
+package libol
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink/nl"
+	"strings"
+	"testing"
+)
+
+// golden returns bytes of hex separated by spaces.
+func golden(values ...string) []byte {
+	data, err := hex.DecodeString(strings.Replace(strings.Join(values, ""), " ", "", -1))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func encodeAll(attrs []*nfAttr) []byte {
+	var data []byte
+	for _, a := range attrs {
+		data = append(data, a.encode()...)
+	}
+	return data
+}
+
+const (
+	nfAcceptGolden = "30000180 0e000100 696d6d65 64696174 65000000" + // immediate
+		"1c000280 08000100 00000000" + // dreg verdict
+		"10000280 0c000280 08000100 00000001" // accept
+)
+
+func TestNfVerdict_Encode(t *testing.T) {
+	assert.Equal(t, golden(nfAcceptGolden), nfVerdict(nfAccept).encode())
+	assert.Equal(t, golden(
+		"30000180 0e000100 696d6d65 64696174 65000000",
+		"1c000280 08000100 00000000",
+		"10000280 0c000280 08000100 fffffffb"), nfVerdict(nftReturn).encode())
+}
+
+func TestNfMatchAddr_Encode(t *testing.T) {
+	exprs, err := nfMatchAddr(12, "192.168.1.0/24")
+	assert.Nil(t, err)
+	assert.Equal(t, golden(
+		// payload load 4 bytes at 12 of network header into reg1.
+		"34000180 0c000100 7061796c 6f616400",
+		"24000280 08000100 00000001 08000200 00000001 08000300 0000000c 08000400 00000004",
+		// bitwise reg1 by mask 255.255.255.0.
+		"44000180 0c000100 62697477 69736500",
+		"34000280 08000100 00000001 08000200 00000001 08000300 00000004",
+		"0c000480 08000100 ffffff00 0c000580 08000100 00000000",
+		// cmp reg1 eq 192.168.1.0.
+		"2c000180 08000100 636d7000",
+		"20000280 08000100 00000001 08000200 00000000 0c000380 08000100 c0a80100"),
+		encodeAll(exprs))
+
+	exprs, err = nfMatchAddr(16, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, golden(
+		"34000180 0c000100 7061796c 6f616400",
+		"24000280 08000100 00000001 08000200 00000001 08000300 00000010 08000400 00000004",
+		"2c000180 08000100 636d7000",
+		"20000280 08000100 00000001 08000200 00000000 0c000380 08000100 0a000001"),
+		encodeAll(exprs), "without bitwise for a host")
+
+	_, err = nfMatchAddr(12, "fe80::1/64")
+	assert.NotNil(t, err)
+}
+
+func TestNfMatchProto_Encode(t *testing.T) {
+	exprs, err := nfMatchProto("tcp", 22)
+	assert.Nil(t, err)
+	assert.Equal(t, golden(
+		// meta l4proto into reg1, and cmp eq 6.
+		"24000180 09000100 6d657461 00000000",
+		"14000280 08000100 00000001 08000200 00000010",
+		"2c000180 08000100 636d7000",
+		"20000280 08000100 00000001 08000200 00000000 0c000380 05000100 06000000",
+		// payload load 2 bytes at 2 of transport header, and cmp eq 22.
+		"34000180 0c000100 7061796c 6f616400",
+		"24000280 08000100 00000001 08000200 00000002 08000300 00000002 08000400 00000002",
+		"2c000180 08000100 636d7000",
+		"20000280 08000100 00000001 08000200 00000000 0c000380 06000100 00160000"),
+		encodeAll(exprs))
+
+	_, err = nfMatchProto("icmp", 0)
+	assert.NotNil(t, err)
+}
+
+func TestNfNat_Encode(t *testing.T) {
+	exprs, err := nfNat(nftNatDnat, "10.0.0.2:8080")
+	assert.Nil(t, err)
+	assert.Equal(t, golden(
+		// immediate address into reg1.
+		"2c000180 0e000100 696d6d65 64696174 65000000",
+		"18000280 08000100 00000001 0c000280 08000100 0a000002",
+		// immediate port into reg2.
+		"2c000180 0e000100 696d6d65 64696174 65000000",
+		"18000280 08000100 00000002 0c000280 06000100 1f900000",
+		// dnat ipv4 by reg1 and reg2.
+		"30000180 08000100 6e617400",
+		"24000280 08000100 00000001 08000200 00000002 08000300 00000001 08000500 00000002"),
+		encodeAll(exprs))
+
+	_, err = nfNat(nftNatSnat, "host:80")
+	assert.NotNil(t, err)
+}
+
+func TestNfTable_Batch(t *testing.T) {
+	if nl.NativeEndian() != binary.ByteOrder(binary.LittleEndian) {
+		t.Skip("golden of little endian")
+	}
+	table := NewNfTable("openlan")
+	skipped := table.batch([]FilterRule{
+		{Table: "filter", Chain: "FORWARD", Jump: "ACCEPT"},
+		{Table: "mangle", Chain: "FORWARD", Jump: "TCPMSS"},
+	})
+	assert.Equal(t, 1, len(skipped))
+	assert.Equal(t, "mangle", skipped[0].Table)
+
+	var batch []byte
+	for _, m := range table.msgs {
+		batch = append(batch, m...)
+	}
+	assert.Equal(t, golden(
+		// batch begin of nftables.
+		"14000000 1000 0100 01000000 00000000 0000000a",
+		// create and delete the table to empty it, then create it.
+		"20000000 000a 0504 02000000 00000000 02000000 0c000100 6f70656e 6c616e00",
+		"20000000 020a 0500 03000000 00000000 02000000 0c000100 6f70656e 6c616e00",
+		"20000000 000a 0504 04000000 00000000 02000000 0c000100 6f70656e 6c616e00",
+		// chain forward hooked on forward by priority 0.
+		"4c000000 030a 0504 05000000 00000000 02000000 0c000100 6f70656e 6c616e00",
+		"0c000300 666f7277 61726400 14000480 08000100 00000002 08000200 00000000",
+		"0b000700 66696c74 65720000",
+		// rule appended to the chain.
+		"60000000 060a 050c 06000000 00000000 02000000 0c000100 6f70656e 6c616e00",
+		"0c000200 666f7277 61726400 34000480", nfAcceptGolden,
+		// batch end.
+		"14000000 1100 0100 07000000 00000000 0000000a"), batch)
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package libol
+
+type NfTable struct {
+	name string
+}
+
+func NewNfTable(name string) *NfTable {
+	return &NfTable{name: name}
+}
+
+func (t *NfTable) Apply(rules []FilterRule) ([]FilterRule, error) {
+	return rules, NewErr("nftables not support")
+}
+
+func (t *NfTable) Clear() error {
+	return NewErr("nftables not support")
+}
@@ -0,0 +1,93 @@
+package libol
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	PcapLinkEthernet = 1
+	PcapSnapLen      = 65535
+)
+
+// PcapWriter writes frames of ethernet by the format of pcap or pcapng.
+type PcapWriter struct {
+	writer io.Writer
+	ng     bool
+}
+
+func NewPcapWriter(w io.Writer, ng bool) (*PcapWriter, error) {
+	p := &PcapWriter{writer: w, ng: ng}
+	if ng {
+		return p, p.writeNgHeader()
+	}
+	return p, p.writeHeader()
+}
+
+func (p *PcapWriter) writeHeader() error {
+	buffer := make([]byte, 24)
+	binary.LittleEndian.PutUint32(buffer[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(buffer[4:6], 2)
+	binary.LittleEndian.PutUint16(buffer[6:8], 4)
+	binary.LittleEndian.PutUint32(buffer[16:20], PcapSnapLen)
+	binary.LittleEndian.PutUint32(buffer[20:24], PcapLinkEthernet)
+	_, err := p.writer.Write(buffer)
+	return err
+}
+
+// writeNgHeader writes a section header and an interface description.
+func (p *PcapWriter) writeNgHeader() error {
+	buffer := make([]byte, 28+20)
+	binary.LittleEndian.PutUint32(buffer[0:4], 0x0a0d0d0a)
+	binary.LittleEndian.PutUint32(buffer[4:8], 28)
+	binary.LittleEndian.PutUint32(buffer[8:12], 0x1a2b3c4d)
+	binary.LittleEndian.PutUint16(buffer[12:14], 1)
+	binary.LittleEndian.PutUint16(buffer[14:16], 0)
+	binary.LittleEndian.PutUint64(buffer[16:24], 0xffffffffffffffff) // unknown length.
+	binary.LittleEndian.PutUint32(buffer[24:28], 28)
+	idb := buffer[28:]
+	binary.LittleEndian.PutUint32(idb[0:4], 1)
+	binary.LittleEndian.PutUint32(idb[4:8], 20)
+	binary.LittleEndian.PutUint16(idb[8:10], PcapLinkEthernet)
+	binary.LittleEndian.PutUint32(idb[12:16], PcapSnapLen)
+	binary.LittleEndian.PutUint32(idb[16:20], 20)
+	_, err := p.writer.Write(buffer)
+	return err
+}
+
+func (p *PcapWriter) WritePacket(t time.Time, data []byte) error {
+	if len(data) > PcapSnapLen {
+		data = data[:PcapSnapLen]
+	}
+	if p.ng {
+		return p.writeNgPacket(t, data)
+	}
+	buffer := make([]byte, 16, 16+len(data))
+	binary.LittleEndian.PutUint32(buffer[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(buffer[4:8], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(buffer[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buffer[12:16], uint32(len(data)))
+	_, err := p.writer.Write(append(buffer, data...))
+	return err
+}
+
+// writeNgPacket writes an enhanced packet block with timestamp in
+// microseconds.
+func (p *PcapWriter) writeNgPacket(t time.Time, data []byte) error {
+	padded := (len(data) + 3) &^ 3
+	size := 32 + padded
+	buffer := make([]byte, size)
+	ts := uint64(t.UnixNano() / 1000)
+	binary.LittleEndian.PutUint32(buffer[0:4], 6)
+	binary.LittleEndian.PutUint32(buffer[4:8], uint32(size))
+	binary.LittleEndian.PutUint32(buffer[8:12], 0)
+	binary.LittleEndian.PutUint32(buffer[12:16], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(buffer[16:20], uint32(ts))
+	binary.LittleEndian.PutUint32(buffer[20:24], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buffer[24:28], uint32(len(data)))
+	copy(buffer[28:], data)
+	binary.LittleEndian.PutUint32(buffer[size-4:], uint32(size))
+	_, err := p.writer.Write(buffer)
+	return err
+}
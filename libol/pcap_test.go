@@ -0,0 +1,31 @@
+package libol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPcapWriter_WritePacket(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	w, err := NewPcapWriter(buffer, false)
+	assert.Nil(t, err)
+	assert.Nil(t, w.WritePacket(time.Now(), make([]byte, 60)))
+	data := buffer.Bytes()
+	assert.Equal(t, 24+16+60, len(data))
+	assert.Equal(t, uint32(0xa1b2c3d4), binary.LittleEndian.Uint32(data[0:4]))
+	assert.Equal(t, uint32(60), binary.LittleEndian.Uint32(data[24+8:24+12]))
+
+	buffer.Reset()
+	w, err = NewPcapWriter(buffer, true)
+	assert.Nil(t, err)
+	assert.Nil(t, w.WritePacket(time.Now(), make([]byte, 61)))
+	data = buffer.Bytes()
+	assert.Equal(t, 28+20+32+64, len(data))
+	epb := data[48:]
+	assert.Equal(t, uint32(6), binary.LittleEndian.Uint32(epb[0:4]))
+	assert.Equal(t, uint32(96), binary.LittleEndian.Uint32(epb[4:8]))
+	assert.Equal(t, uint32(96), binary.LittleEndian.Uint32(epb[92:96]))
+}
@@ -508,3 +508,86 @@ func (u *Udp) Encode() []byte {
 
 	return buffer[:u.Len]
 }
+
+const (
+	IgmpQuery    = 0x11
+	IgmpV1Report = 0x12
+	IgmpV2Report = 0x16
+	IgmpV2Leave  = 0x17
+	IgmpV3Report = 0x22
+)
+
+const (
+	IgmpIsInclude = 0x01
+	IgmpIsExclude = 0x02
+	IgmpToInclude = 0x03
+	IgmpToExclude = 0x04
+	IgmpAllow     = 0x05
+	IgmpBlock     = 0x06
+)
+
+const IgmpLen = 8
+
+type IgmpRecord struct {
+	Type    uint8
+	AuxLen  uint8
+	Sources uint16
+	Group   []byte
+}
+
+type Igmp struct {
+	Type     uint8
+	MaxResp  uint8
+	Checksum uint16
+	Group    []byte
+	Records  []IgmpRecord // only for v3 report
+	Len      int
+}
+
+func NewIgmp() (i *Igmp) {
+	i = &Igmp{
+		Group: make([]byte, 4),
+		Len:   IgmpLen,
+	}
+	return
+}
+
+func NewIgmpFromFrame(frame []byte) (i *Igmp, err error) {
+	i = NewIgmp()
+	err = i.Decode(frame)
+	return
+}
+
+func (i *Igmp) Decode(frame []byte) error {
+	if len(frame) < IgmpLen {
+		return NewErr("Igmp.Decode: too small header: %d", len(frame))
+	}
+
+	i.Type = uint8(frame[0])
+	i.MaxResp = uint8(frame[1])
+	i.Checksum = binary.BigEndian.Uint16(frame[2:4])
+	if i.Type != IgmpV3Report {
+		copy(i.Group[:4], frame[4:8])
+		return nil
+	}
+	// v3 report has records after the number of records.
+	num := binary.BigEndian.Uint16(frame[6:8])
+	p := IgmpLen
+	for k := 0; k < int(num); k++ {
+		if len(frame) < p+8 {
+			return NewErr("Igmp.Decode: too small record: %d", len(frame))
+		}
+		r := IgmpRecord{
+			Type:    uint8(frame[p]),
+			AuxLen:  uint8(frame[p+1]),
+			Sources: binary.BigEndian.Uint16(frame[p+2 : p+4]),
+			Group:   make([]byte, 4),
+		}
+		copy(r.Group[:4], frame[p+4:p+8])
+		p += 8 + int(r.Sources)*4 + int(r.AuxLen)*4
+		i.Records = append(i.Records, r)
+	}
+	i.Len = p
+
+	return nil
+}
@@ -3,6 +3,7 @@ package libol
 import (
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,11 +17,15 @@ const (
 	ClClosed     = 0x06
 )
 
+// ClientSts counts bytes of frames without the header of message.
 type ClientSts struct {
 	SendOkay  uint64 `json:"send"`
 	RecvOkay  uint64 `json:"recv"`
 	SendError uint64 `json:"error"`
 	Dropped   uint64 `json:"dropped"`
+	SendFrame uint64 `json:"sendFrames"`
+	RecvFrame uint64 `json:"recvFrames"`
+	RecvError uint64 `json:"recvError"`
 }
 
 type ClientListener struct {
@@ -80,7 +85,7 @@ func (t *dataStream) IsOk() bool {
 
 func (t *dataStream) WriteMsg(data []byte) error {
 	if err := t.connecter(); err != nil {
-		t.sts.Dropped++
+		atomic.AddUint64(&t.sts.Dropped, 1)
 		return err
 	}
 	if t.message == nil { // default is stream message
@@ -88,10 +93,11 @@ func (t *dataStream) WriteMsg(data []byte) error {
 	}
 	n, err := t.message.Send(t.connection, data)
 	if err != nil {
-		t.sts.SendError++
+		atomic.AddUint64(&t.sts.SendError, 1)
 		return err
 	}
-	t.sts.SendOkay += uint64(n)
+	atomic.AddUint64(&t.sts.SendOkay, uint64(n))
+	atomic.AddUint64(&t.sts.SendFrame, 1)
 	return nil
 }
 
@@ -105,9 +111,11 @@ func (t *dataStream) ReadMsg(data []byte) (int, error) {
 	}
 	size, err := t.message.Receive(t.connection, data, t.maxSize, t.minSize)
 	if err != nil {
+		atomic.AddUint64(&t.sts.RecvError, 1)
 		return size, err
 	}
-	t.sts.RecvOkay += uint64(size)
+	atomic.AddUint64(&t.sts.RecvOkay, uint64(size))
+	atomic.AddUint64(&t.sts.RecvFrame, 1)
 
 	return size, nil
 }
@@ -208,7 +216,15 @@ func (s *socketClient) Have(state uint8) bool {
 }
 
 func (s *socketClient) Sts() ClientSts {
-	return s.sts
+	return ClientSts{
+		SendOkay:  atomic.LoadUint64(&s.sts.SendOkay),
+		RecvOkay:  atomic.LoadUint64(&s.sts.RecvOkay),
+		SendError: atomic.LoadUint64(&s.sts.SendError),
+		Dropped:   atomic.LoadUint64(&s.sts.Dropped),
+		SendFrame: atomic.LoadUint64(&s.sts.SendFrame),
+		RecvFrame: atomic.LoadUint64(&s.sts.RecvFrame),
+		RecvError: atomic.LoadUint64(&s.sts.RecvError),
+	}
 }
 
 func (s *socketClient) SetListener(listener ClientListener) {
@@ -237,6 +253,7 @@ func (s *socketClient) SetTimeout(v int64) {
 
 type ServerSts struct {
 	RecvCount   int64 `json:"recv"`
+	RecvBytes   int64 `json:"recvBytes"`
 	SendCount   int64 `json:"send"`
 	DropCount   int64 `json:"dropped"`
 	AcceptCount int64 `json:"accept"`
@@ -311,7 +328,7 @@ func (t *socketServer) doOnClient(call ServerListener, client SocketClient) {
 func (t *socketServer) doOffClient(call ServerListener, client SocketClient) {
 	Debug("socketServer.doOffClient: %s", client.Addr())
 	if _, ok := t.clients.GetEx(client.RemoteAddr()); ok {
-		t.sts.CloseCount++
+		atomic.AddInt64(&t.sts.CloseCount, 1)
 		if call.OnClose != nil {
 			_ = call.OnClose(client)
 		}
@@ -346,7 +363,8 @@ func (t *socketServer) Read(client SocketClient, ReadAt ReadClient) {
 		if length <= 0 {
 			continue
 		}
-		t.sts.RecvCount++
+		atomic.AddInt64(&t.sts.RecvCount, 1)
+		atomic.AddInt64(&t.sts.RecvBytes, int64(length))
 		Log("socketServer.Read: length: %d ", length)
 		Log("socketServer.Read: data  : %x", data[:length])
 		if err := ReadAt(client, data[:length]); err != nil {
@@ -371,7 +389,14 @@ func (t *socketServer) String() string {
 }
 
 func (t *socketServer) Sts() ServerSts {
-	return t.sts
+	return ServerSts{
+		RecvCount:   atomic.LoadInt64(&t.sts.RecvCount),
+		RecvBytes:   atomic.LoadInt64(&t.sts.RecvBytes),
+		SendCount:   atomic.LoadInt64(&t.sts.SendCount),
+		DropCount:   atomic.LoadInt64(&t.sts.DropCount),
+		AcceptCount: atomic.LoadInt64(&t.sts.AcceptCount),
+		CloseCount:  atomic.LoadInt64(&t.sts.CloseCount),
+	}
 }
 
 func (t *socketServer) SetTimeout(v int64) {
@@ -0,0 +1,34 @@
+package libol
+
+import (
+	"log/syslog"
+)
+
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+func newSyslog(network, addr string) (logSink, error) {
+	writer, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, "openlan")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+func (s *syslogSink) Write(level int, line string) {
+	switch {
+	case level >= ERROR:
+		_ = s.writer.Err(line)
+	case level >= WARN:
+		_ = s.writer.Warning(line)
+	case level >= INFO:
+		_ = s.writer.Info(line)
+	default:
+		_ = s.writer.Debug(line)
+	}
+}
+
+func (s *syslogSink) Close() error {
+	return s.writer.Close()
+}
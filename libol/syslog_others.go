@@ -0,0 +1,7 @@
+// +build !linux
+
+package libol
+
+func newSyslog(network, addr string) (logSink, error) {
+	return nil, NewErr("syslog not support")
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"github.com/xtaci/kcp-go/v5"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -85,7 +86,7 @@ func (t *TcpServer) Accept() {
 			Error("TcpServer.Accept: %s", err)
 			return
 		}
-		t.sts.AcceptCount++
+		atomic.AddInt64(&t.sts.AcceptCount, 1)
 		t.onClients <- NewTcpClientFromConn(conn, t.tcpCfg)
 	}
 }
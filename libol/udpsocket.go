@@ -3,6 +3,7 @@ package libol
 import (
 	"github.com/xtaci/kcp-go/v5"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -78,7 +79,7 @@ func (k *UdpServer) Accept() {
 			Error("TcpServer.Accept: %s", err)
 			return
 		}
-		k.sts.AcceptCount++
+		atomic.AddInt64(&k.sts.AcceptCount, 1)
 		k.onClients <- NewUdpClientFromConn(conn, k.udpCfg)
 	}
 }
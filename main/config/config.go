@@ -7,11 +7,41 @@ import (
 	"github.com/xtaci/kcp-go/v5"
 	"os"
 	"strings"
+	"time"
 )
 
 type Log struct {
-	File    string `json:"file,omitempty" yaml:"file,omitempty"`
-	Verbose int    `json:"level,omitempty" yaml:"level,omitempty"`
+	File    string         `json:"file,omitempty" yaml:"file,omitempty"`
+	Verbose int            `json:"level,omitempty" yaml:"level,omitempty"`
+	Format  string         `json:"format,omitempty" yaml:"format,omitempty"`   // text/json/logfmt.
+	MaxSize int            `json:"maxSize,omitempty" yaml:"maxSize,omitempty"` // MB.
+	Rotate  string         `json:"rotate,omitempty" yaml:"rotate,omitempty"`   // hourly/daily.
+	Backups int            `json:"backups,omitempty" yaml:"backups,omitempty"`
+	MaxAge  int            `json:"maxAge,omitempty" yaml:"maxAge,omitempty"` // days.
+	Syslog  string         `json:"syslog,omitempty" yaml:"syslog,omitempty"` // local or udp://host:514.
+	Modules map[string]int `json:"modules,omitempty" yaml:"modules,omitempty"`
+}
+
+// Init initializes the logger by the configuration.
+func (l *Log) Init() {
+	libol.Init(l.File, l.Verbose)
+	if err := libol.SetFormat(l.Format); err != nil {
+		libol.Warn("Log.Init %s", err)
+	}
+	interval := time.Duration(0)
+	switch l.Rotate {
+	case "hourly":
+		interval = time.Hour
+	case "daily":
+		interval = 24 * time.Hour
+	}
+	libol.SetRotate(int64(l.MaxSize)*1024*1024, interval, l.Backups, time.Duration(l.MaxAge)*24*time.Hour)
+	if err := libol.SetSyslog(l.Syslog); err != nil {
+		libol.Warn("Log.Init %s", err)
+	}
+	for name, level := range l.Modules {
+		libol.SetModule(name, level)
+	}
 }
 
 type Http struct {
@@ -82,7 +82,7 @@ func NewPoint() (c *Point) {
 		libol.Warn("NewPoint.load %s", err)
 	}
 	c.Default()
-	libol.Init(c.Log.File, c.Log.Verbose)
+	c.Log.Init()
 	return c
 }
 
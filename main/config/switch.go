@@ -7,11 +7,30 @@ import (
 	"path/filepath"
 )
 
+type StormLimit struct {
+	Broadcast int `json:"broadcast,omitempty" yaml:"broadcast,omitempty"`
+	Multicast int `json:"multicast,omitempty" yaml:"multicast,omitempty"`
+	Unknown   int `json:"unknown,omitempty" yaml:"unknown,omitempty"`
+}
+
+// StormControl limits flooded frames per second on each port and the bridge.
+type StormControl struct {
+	Port   StormLimit `json:"port" yaml:"port"`
+	Bridge StormLimit `json:"bridge" yaml:"bridge"`
+}
+
+type Snooping struct {
+	Enable  bool `json:"enable"`
+	Timeout int  `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
 type Bridge struct {
-	Name     string `json:"name"`
-	IfMtu    int    `json:"mtu"`
-	Address  string `json:"address,omitempty" yaml:"address,omitempty"`
-	Provider string `json:"provider"`
+	Name     string        `json:"name"`
+	IfMtu    int           `json:"mtu"`
+	Address  string        `json:"address,omitempty" yaml:"address,omitempty"`
+	Provider string        `json:"provider"`
+	Storm    *StormControl `json:"storm,omitempty" yaml:"storm,omitempty"`
+	Snooping *Snooping     `json:"snooping,omitempty" yaml:"snooping,omitempty"`
 }
 
 type IpSubnet struct {
@@ -25,19 +44,105 @@ type PrefixRoute struct {
 	NextHop string `json:"nexthop"`
 }
 
+// Peer allows a network to reach prefixes of another network, and the
+// whole subnet of that network if no prefixes.
+type Peer struct {
+	Network  string   `json:"network"`
+	Prefixes []string `json:"prefixes,omitempty" yaml:"prefixes,omitempty"`
+	Nat      bool     `json:"nat,omitempty" yaml:"nat,omitempty"`
+}
+
+// Dhcp offers the bridge address as router, and the default route in
+// classless routes only if enabled.
+type Dhcp struct {
+	Enable       bool     `json:"enable"`
+	LeaseTime    int      `json:"leaseTime,omitempty" yaml:"leaseTime,omitempty"`
+	Dns          []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	Router       bool     `json:"router,omitempty" yaml:"router,omitempty"`
+	DefaultRoute bool     `json:"defaultRoute,omitempty" yaml:"defaultRoute,omitempty"`
+}
+
+type Dns struct {
+	Enable   bool     `json:"enable"`
+	Domain   string   `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Upstream []string `json:"upstream,omitempty" yaml:"upstream,omitempty"`
+}
+
+// Resolver is pushed to points with the address.
+type Resolver struct {
+	Servers []string `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Search  []string `json:"search,omitempty" yaml:"search,omitempty"`
+}
+
 type Password struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+type Isolation struct {
+	Enable  bool     `json:"enable"`
+	Uplinks []string `json:"uplinks,omitempty" yaml:"uplinks,omitempty"`
+}
+
+type SourceGuard struct {
+	Enable    bool `json:"enable"`
+	MaxHwAddr int  `json:"maxHwAddr,omitempty" yaml:"maxHwAddr,omitempty"`
+}
+
+// Proxy accepts socks5 and http connect from users of the network, and
+// dials to the allowed destinations from the bridge address.
+type Proxy struct {
+	Enable  bool     `json:"enable"`
+	Listen  string   `json:"listen"`
+	Allowed []string `json:"allowed,omitempty" yaml:"allowed,omitempty"` // prefix or prefix:port.
+}
+
+// AclRule matches frames from points, and an empty field matches any.
+type AclRule struct {
+	Name    string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Users   []string `json:"users,omitempty" yaml:"users,omitempty"`
+	Groups  []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	SrcMac  string   `json:"srcMac,omitempty" yaml:"srcMac,omitempty"`
+	DstMac  string   `json:"dstMac,omitempty" yaml:"dstMac,omitempty"`
+	Vlan    int      `json:"vlan,omitempty" yaml:"vlan,omitempty"`
+	Source  string   `json:"source,omitempty" yaml:"source,omitempty"`
+	Dest    string   `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Proto   string   `json:"protocol,omitempty" yaml:"protocol,omitempty"` // tcp/udp/icmp or number.
+	SrcPort string   `json:"srcPort,omitempty" yaml:"srcPort,omitempty"`   // 80 or 8000-8080.
+	DstPort string   `json:"dstPort,omitempty" yaml:"dstPort,omitempty"`
+	Action  string   `json:"action" yaml:"action"` // allow/deny/log.
+}
+
+// Acl takes the action of the first matched rule, and allows the frame
+// if nothing matched. A rule with log action only logs it and goes on.
+type Acl struct {
+	Enable bool                `json:"enable"`
+	Groups map[string][]string `json:"groups,omitempty" yaml:"groups,omitempty"` // group -> users.
+	Rules  []AclRule           `json:"rules" yaml:"rules"`
+}
+
 type Network struct {
-	Alias    string        `json:"-"`
-	Name     string        `json:"name" yaml:"name"`
-	Bridge   Bridge        `json:"bridge" yaml:"bridge"`
-	Links    []*Point      `json:"links" yaml:"links"`
-	Routes   []PrefixRoute `json:"routes"`
-	Subnet   IpSubnet      `json:"subnet"`
-	Password []Password    `json:"password"`
+	Alias       string        `json:"-"`
+	Name        string        `json:"name" yaml:"name"`
+	Bridge      Bridge        `json:"bridge" yaml:"bridge"`
+	Links       []*Point      `json:"links" yaml:"links"`
+	Routes      []PrefixRoute `json:"routes"`
+	Subnet      IpSubnet      `json:"subnet"`
+	Password    []Password    `json:"password"`
+	Isolation   *Isolation    `json:"isolation,omitempty" yaml:"isolation,omitempty"`
+	ArpSuppress bool          `json:"arpSuppress,omitempty" yaml:"arpSuppress,omitempty"`
+	Guard       *SourceGuard  `json:"sourceGuard,omitempty" yaml:"sourceGuard,omitempty"`
+	Mode        string        `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Peers       []Peer        `json:"peers,omitempty" yaml:"peers,omitempty"`
+	Dhcp        *Dhcp         `json:"dhcp,omitempty" yaml:"dhcp,omitempty"`
+	Dns         *Dns          `json:"dns,omitempty" yaml:"dns,omitempty"`
+	Resolver    *Resolver     `json:"resolver,omitempty" yaml:"resolver,omitempty"`
+	Acl         *Acl          `json:"acl,omitempty" yaml:"acl,omitempty"`
+	Proxy       *Proxy        `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+}
+
+func (n *Network) IsRouted() bool {
+	return n.Mode == "routed"
 }
 
 func (n *Network) Right() {
@@ -50,6 +155,25 @@ func (n *Network) Right() {
 	if n.Bridge.IfMtu == 0 {
 		n.Bridge.IfMtu = 1518
 	}
+	if n.Mode == "" {
+		n.Mode = "bridge"
+	}
+	if n.Dhcp != nil && n.Dhcp.LeaseTime == 0 {
+		n.Dhcp.LeaseTime = 3600
+	}
+	if n.Dns != nil && n.Dns.Domain == "" {
+		n.Dns.Domain = "openlan"
+	}
+	if n.Guard != nil && n.Guard.MaxHwAddr == 0 {
+		n.Guard.MaxHwAddr = 1
+	}
+	if n.Acl != nil {
+		for i := range n.Acl.Rules {
+			if n.Acl.Rules[i].Action == "" {
+				n.Acl.Rules[i].Action = "deny"
+			}
+		}
+	}
 }
 
 type Cert struct {
@@ -69,6 +193,64 @@ type FlowRules struct {
 	Output   string `json:"output"`
 	Comment  string `json:"comment"`
 	Jump     string `json:"jump"` // SNAT/RETURN/MASQUERADE
+	Proto    string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	DstPort  int    `json:"dport,omitempty" yaml:"dport,omitempty"`
+}
+
+// Forward publishes the port of a point on the port of switch.
+type Forward struct {
+	Network   string `json:"network"`
+	Protocol  string `json:"protocol"` // tcp/udp.
+	Port      int    `json:"port"`
+	Point     string `json:"point"` // alias or uuid.
+	PointPort int    `json:"pointPort" yaml:"pointPort"`
+}
+
+// OnLine configures the flow table of each network.
+type OnLine struct {
+	Size    int `json:"size,omitempty" yaml:"size,omitempty"`
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty"` // idle seconds.
+}
+
+// FlowExport sends records of flows to collectors by ipfix or netflow9,
+// and timeouts are seconds.
+type FlowExport struct {
+	Enable     bool     `json:"enable"`
+	Protocol   string   `json:"protocol,omitempty" yaml:"protocol,omitempty"` // ipfix/netflow9.
+	Collectors []string `json:"collectors" yaml:"collectors"`
+	Active     int      `json:"active,omitempty" yaml:"active,omitempty"`
+	Inactive   int      `json:"inactive,omitempty" yaml:"inactive,omitempty"`
+	Domain     uint32   `json:"domain,omitempty" yaml:"domain,omitempty"`
+}
+
+// Webhook posts alerts in json, signed by hmac-sha256 of the secret if
+// given, and alerts are all types if events is empty.
+type Webhook struct {
+	Url     string   `json:"url"`
+	Secret  string   `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Events  []string `json:"events,omitempty" yaml:"events,omitempty"`
+	Retries int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Timeout int      `json:"timeout,omitempty" yaml:"timeout,omitempty"` // seconds.
+}
+
+// Alert watches points, links, authentication and so on, and notifies
+// webhooks. A point or link is offline if it's down out of seconds of
+// offline, and failures of authentication spike if more than failures
+// in a minute.
+type Alert struct {
+	Webhooks []Webhook `json:"webhooks" yaml:"webhooks"`
+	Offline  int       `json:"offline,omitempty" yaml:"offline,omitempty"`
+	Points   []string  `json:"points,omitempty" yaml:"points,omitempty"` // uuid, all if empty.
+	Failures int       `json:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+// Audit configures the file of audit log, which is conf:dir/audit.log by
+// default, and rotated if larger than size in MB.
+type Audit struct {
+	File    string `json:"file,omitempty" yaml:"file,omitempty"`
+	MaxSize int    `json:"size,omitempty" yaml:"size,omitempty"`
+	Backups int    `json:"backups,omitempty" yaml:"backups,omitempty"`
+	MaxAge  int    `json:"age,omitempty" yaml:"age,omitempty"` // days.
 }
 
 type Switch struct {
@@ -82,6 +264,12 @@ type Switch struct {
 	Crypt     *Crypt      `json:"crypt"`
 	Network   []*Network  `json:"network"`
 	FireWall  []FlowRules `json:"firewall"`
+	Forward   []Forward   `json:"forward,omitempty" yaml:"forward,omitempty"`
+	Backend   string      `json:"backend,omitempty" yaml:"backend,omitempty"` // iptables/nftables.
+	Online    *OnLine     `json:"online,omitempty" yaml:"online,omitempty"`
+	Export    *FlowExport `json:"export,omitempty" yaml:"export,omitempty"`
+	Alert     *Alert      `json:"alert,omitempty" yaml:"alert,omitempty"`
+	Audit     *Audit      `json:"audit,omitempty" yaml:"audit,omitempty"`
 	ConfDir   string      `json:"-" yaml:"-"`
 	TokenFile string      `json:"-" yaml:"-"`
 	SaveFile  string      `json:"-" yaml:"-"`
@@ -110,7 +298,7 @@ func NewSwitch() (c Switch) {
 		libol.Error("NewSwitch.load %s", err)
 	}
 	c.Default()
-	libol.Init(c.Log.File, c.Log.Verbose)
+	c.Log.Init()
 	libol.Debug("NewSwitch %v", c)
 	return c
 }
@@ -139,6 +327,9 @@ func (c *Switch) Default() {
 	if c.Timeout == 0 {
 		c.Timeout = sd.Timeout
 	}
+	if c.Backend == "" {
+		c.Backend = "iptables"
+	}
 	if c.Crypt != nil {
 		c.Crypt.Default()
 	}
@@ -169,6 +360,27 @@ func (c *Switch) Load() error {
 	return libol.UnmarshalLoad(c, c.SaveFile)
 }
 
+// saveKey updates the value of key only in the saved file, and keeps
+// others as it is.
+func (c *Switch) saveKey(key string, value interface{}) error {
+	values := make(map[string]interface{}, 32)
+	if err := libol.FileExist(c.SaveFile); err == nil {
+		if err := libol.UnmarshalLoad(&values, c.SaveFile); err != nil {
+			return err
+		}
+	}
+	values[key] = value
+	return libol.MarshalSave(values, c.SaveFile, true)
+}
+
+func (c *Switch) SaveFireWall() error {
+	return c.saveKey("firewall", c.FireWall)
+}
+
+func (c *Switch) SaveForward() error {
+	return c.saveKey("forward", c.Forward)
+}
+
 func init() {
 	sd.Right()
 }
@@ -3,18 +3,46 @@ package models
 import (
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	TcpNone = iota
+	TcpSynSent
+	TcpSynRecv
+	TcpEstablished
+	TcpFinWait
+	TcpClosed
+)
+
+var tcpStates = map[uint32]string{
+	TcpNone:        "",
+	TcpSynSent:     "SYN_SENT",
+	TcpSynRecv:     "SYN_RECV",
+	TcpEstablished: "ESTABLISHED",
+	TcpFinWait:     "FIN_WAIT",
+	TcpClosed:      "CLOSED",
+}
+
 type Line struct {
-	EthType    uint16
-	IpSource   net.IP
-	IpDest     net.IP
-	IpProtocol uint8
-	PortDest   uint16
-	PortSource uint16
-	NewTime    int64
-	HitTime    int64
+	EthType      uint16
+	IpSource     net.IP
+	IpDest       net.IP
+	IpProtocol   uint8
+	PortDest     uint16
+	PortSource   uint16
+	NewTime      int64
+	HitTime      int64
+	User         string
+	Proxy        string // socks5/http if by proxy.
+	Network      string
+	Point        string // uuid of point sent the first frame.
+	Packets      uint64 // from source.
+	Bytes        uint64
+	ReplyPackets uint64 // from destination.
+	ReplyBytes   uint64
+	State        uint32 // tcp state.
 }
 
 func NewLine(t uint16) *Line {
@@ -30,10 +58,57 @@ func NewLine(t uint16) *Line {
 }
 
 func (l *Line) String() string {
+	if l.Proxy != "" {
+		return fmt.Sprintf("%s:%s:%s:%s:%d:%d",
+			l.Proxy, l.User, l.IpSource, l.IpDest, l.PortSource, l.PortDest)
+	}
+	if l.Network != "" {
+		return fmt.Sprintf("%s:%d:%s:%s:%d:%d:%d", l.Network,
+			l.EthType, l.IpSource, l.IpDest, l.IpProtocol, l.PortSource, l.PortDest)
+	}
 	return fmt.Sprintf("%d:%s:%s:%d:%d:%d",
 		l.EthType, l.IpSource, l.IpDest, l.IpProtocol, l.PortSource, l.PortDest)
 }
 
+// Reply returns the key of frames from destination.
+func (l *Line) Reply() string {
+	r := Line{
+		EthType:    l.EthType,
+		IpSource:   l.IpDest,
+		IpDest:     l.IpSource,
+		IpProtocol: l.IpProtocol,
+		PortSource: l.PortDest,
+		PortDest:   l.PortSource,
+		Network:    l.Network,
+	}
+	return r.String()
+}
+
+// Hit counts a frame of size, which is from destination if reply.
+func (l *Line) Hit(size int, reply bool) {
+	atomic.StoreInt64(&l.HitTime, time.Now().Unix())
+	if reply {
+		atomic.AddUint64(&l.ReplyPackets, 1)
+		atomic.AddUint64(&l.ReplyBytes, uint64(size))
+	} else {
+		atomic.AddUint64(&l.Packets, 1)
+		atomic.AddUint64(&l.Bytes, uint64(size))
+	}
+}
+
+func (l *Line) SetState(state uint32) {
+	atomic.StoreUint32(&l.State, state)
+}
+
+func (l *Line) GetState() uint32 {
+	return atomic.LoadUint32(&l.State)
+}
+
+func (l *Line) StateStr() string {
+	return tcpStates[l.GetState()]
+}
+
+// UpTime returns seconds since last hit.
 func (l *Line) UpTime() int64 {
-	return time.Now().Unix() - l.HitTime
+	return time.Now().Unix() - atomic.LoadInt64(&l.HitTime)
 }
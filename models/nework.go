@@ -31,6 +31,8 @@ type Network struct {
 	IpEnd   string   `json:"ipEnd"`
 	Netmask string   `json:"netmask"`
 	Routes  []*Route `json:"routes"`
+	Dns     []string `json:"dns,omitempty"`
+	Search  []string `json:"search,omitempty"`
 }
 
 func NewNetwork(name string, ifAddr string) (this *Network) {
@@ -9,6 +9,7 @@ type Point struct {
 	UUID    string             `json:"uuid"`
 	Alias   string             `json:"alias"`
 	Network string             `json:"Network"`
+	User    string             `json:"user"`
 	Server  string             `json:"server"`
 	Uptime  int64              `json:"uptime"`
 	Status  string             `json:"status"`
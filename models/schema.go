@@ -2,21 +2,29 @@ package models
 
 import (
 	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/network"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 func NewPointSchema(p *Point) schema.Point {
 	client, dev := p.Client, p.Device
+	sts := client.Sts()
 	return schema.Point{
 		Uptime:  p.Uptime,
 		UUID:    p.UUID,
 		Alias:   p.Alias,
+		User:    p.User,
 		Address: client.Addr(),
-		Device:  dev.Name(),
-		RxBytes: client.Sts().RecvOkay,
-		TxBytes: client.Sts().SendOkay,
-		ErrPkt:  client.Sts().SendError,
+		Device:  devName(dev),
+		RxBytes: sts.RecvOkay,
+		TxBytes: sts.SendOkay,
+		ErrPkt:  sts.SendError,
+		RxPkt:   sts.RecvFrame,
+		TxPkt:   sts.SendFrame,
+		Dropped: sts.Dropped,
 		State:   client.State(),
 		Network: p.Network,
 	}
@@ -27,7 +35,7 @@ func NewLinkSchema(p *Point) schema.Link {
 	return schema.Link{
 		UUID:    p.UUID,
 		Uptime:  client.UpTime(),
-		Device:  dev.Name(),
+		Device:  devName(dev),
 		Address: client.Addr(),
 		State:   client.State(),
 		IpAddr:  strings.Split(client.Addr(), ":")[0],
@@ -53,6 +61,16 @@ func NewOnLineSchema(l *Line) schema.OnLine {
 		IpProto:    libol.IpProto2Str(l.IpProtocol),
 		PortSource: l.PortSource,
 		PortDest:   l.PortDest,
+		User:       l.User,
+		Proxy:      l.Proxy,
+		Network:    l.Network,
+		Point:      l.Point,
+		State:      l.StateStr(),
+		Age:        time.Now().Unix() - l.NewTime,
+		Packets:    atomic.LoadUint64(&l.Packets),
+		Bytes:      atomic.LoadUint64(&l.Bytes),
+		RxPackets:  atomic.LoadUint64(&l.ReplyPackets),
+		RxBytes:    atomic.LoadUint64(&l.ReplyBytes),
 	}
 }
 
@@ -91,3 +109,10 @@ func NewNetworkSchema(n *Network) schema.Network {
 	}
 	return sn
 }
+
+func devName(dev network.Taper) string {
+	if dev == nil {
+		return ""
+	}
+	return dev.Name()
+}
@@ -10,12 +10,16 @@ type LinuxBridge struct {
 	ifMtu   int
 	name    string
 	device  netlink.Link
+	isolate bool
+	uplinks map[string]bool
+	snoop   bool
 }
 
 func NewLinuxBridge(name string, mtu int) *LinuxBridge {
 	b := &LinuxBridge{
-		name:  name,
-		ifMtu: mtu,
+		name:    name,
+		ifMtu:   mtu,
+		uplinks: make(map[string]bool, 32),
 	}
 	return b
 }
@@ -47,6 +51,11 @@ func (b *LinuxBridge) Open(addr string) {
 	if err := brCtl.Stp(true); err != nil {
 		libol.Error("LinuxBridge.newBr.Stp: %s", err)
 	}
+	if b.snoop {
+		if err := brCtl.McastSnooping(true); err != nil {
+			libol.Error("LinuxBridge.newBr.McastSnooping: %s", err)
+		}
+	}
 	if err = netlink.LinkSetUp(link); err != nil {
 		libol.Error("LinuxBridge.newBr: %s", err)
 	}
@@ -97,6 +106,12 @@ func (b *LinuxBridge) AddSlave(dev Taper) error {
 		return err
 	}
 
+	if b.isolate && !b.uplinks[name] {
+		brPort := libol.NewBrPort(name)
+		if err := brPort.Isolated(true); err != nil {
+			libol.Error("LinuxBridge.AddSlave.Isolated: %s %s", name, err)
+		}
+	}
 	dev.Slave(b)
 	libol.Info("LinuxBridge.AddSlave: %s %s", name, b.name)
 
@@ -144,6 +159,38 @@ func (b *LinuxBridge) SetTimeout(value int) {
 	//TODO
 }
 
+func (b *LinuxBridge) SetStorm(port, bridge StormLimit) {
+	libol.Warn("LinuxBridge.SetStorm: %s not support", b.name)
+}
+
+func (b *LinuxBridge) StormSts() []StormSts {
+	return nil
+}
+
+func (b *LinuxBridge) SetSnooping(enable bool, timeout int) {
+	b.snoop = enable
+}
+
+func (b *LinuxBridge) McastMembers() []McastMember {
+	entries, err := libol.NewBrCtl(b.name).McastMdb()
+	if err != nil {
+		libol.Warn("LinuxBridge.McastMembers: %s", err)
+		return nil
+	}
+	members := make([]McastMember, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, McastMember{Group: e.Group, Device: e.Port})
+	}
+	return members
+}
+
+func (b *LinuxBridge) SetIsolation(enable bool, uplinks []string) {
+	b.isolate = enable
+	for _, name := range uplinks {
+		b.uplinks[name] = true
+	}
+}
+
 func (b *LinuxBridge) Mtu() int {
 	return b.ifMtu
 }
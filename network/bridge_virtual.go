@@ -25,6 +25,10 @@ type VirtualBridge struct {
 	timeout  int
 	address  string
 	device   Taper
+	isolate  bool
+	uplinks  map[string]bool
+	storm    *StormControl
+	snoop    *Snooping
 }
 
 func NewVirtualBridge(name string, mtu int) *VirtualBridge {
@@ -36,6 +40,9 @@ func NewVirtualBridge(name string, mtu int) *VirtualBridge {
 		done:     make(chan bool),
 		ticker:   time.NewTicker(5 * time.Second),
 		timeout:  5 * 60,
+		uplinks:  make(map[string]bool, 32),
+		storm:    NewStormControl(name),
+		snoop:    NewSnooping(name),
 	}
 	return b
 }
@@ -95,6 +102,8 @@ func (b *VirtualBridge) DelSlave(dev Taper) error {
 	if _, ok := b.devices[dev.Name()]; ok {
 		delete(b.devices, dev.Name())
 	}
+	b.storm.Del(dev.Name())
+	b.snoop.Del(dev.Name())
 
 	libol.Info("VirtualBridge.DelSlave: %s %s", dev.Name(), b.name)
 
@@ -117,6 +126,57 @@ func (b *VirtualBridge) SetTimeout(value int) {
 	b.timeout = value
 }
 
+func (b *VirtualBridge) SetIsolation(enable bool, uplinks []string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.isolate = enable
+	for _, name := range uplinks {
+		b.uplinks[name] = true
+	}
+}
+
+func (b *VirtualBridge) SetStorm(port, bridge StormLimit) {
+	b.storm.SetLimit(port, bridge)
+}
+
+func (b *VirtualBridge) StormSts() []StormSts {
+	return b.storm.Sts()
+}
+
+func (b *VirtualBridge) SetSnooping(enable bool, timeout int) {
+	b.snoop.SetEnable(enable, timeout)
+}
+
+func (b *VirtualBridge) McastMembers() []McastMember {
+	return b.snoop.List()
+}
+
+// isolated returns true if dev is a client port of an isolated bridge.
+func (b *VirtualBridge) isolated(dev Taper) bool {
+	if !b.isolate || dev == nil || dev == b.device {
+		return false
+	}
+	return !b.uplinks[dev.Name()]
+}
+
+// Isolated returns true if frames from src are not allowed to reach dst.
+func (b *VirtualBridge) Isolated(src, dst Taper) bool {
+	return b.isolated(src) && b.isolated(dst)
+}
+
+// Lookup returns the device learned for the destination and whether
+// it's isolated from src, or nil if the frame will be flooded.
+func (b *VirtualBridge) Lookup(src Taper, dest []byte) (Taper, bool) {
+	if len(dest) < 6 {
+		return nil, false
+	}
+	if l := b.FindDest(b.Eth2Str(dest[:6])); l != nil {
+		return l.Device, b.Isolated(src, l.Device)
+	}
+	return nil, false
+}
+
 func (b *VirtualBridge) Forward(m *Framer) error {
 	if is := b.Unicast(m); !is {
 		_ = b.Flood(m)
@@ -160,6 +220,7 @@ func (b *VirtualBridge) Start() {
 			case t := <-b.ticker.C:
 				libol.Debug("VirtualBridge.Expire Tick at %s", t)
 				_ = b.Expire()
+				b.snoop.Expire()
 			}
 		}
 	})
@@ -167,6 +228,7 @@ func (b *VirtualBridge) Start() {
 
 func (b *VirtualBridge) Input(m *Framer) error {
 	b.Learn(m)
+	b.snoop.Learn(m.Source, m.Data)
 	return b.Forward(m)
 }
 
@@ -244,10 +306,27 @@ func (b *VirtualBridge) Flood(m *Framer) error {
 	data := m.Data
 	src := m.Source
 	libol.Debug("VirtualBridge.Flood: % x", data[:20])
+	if !b.storm.Allow(src, data[:6]) {
+		return nil
+	}
+	var members map[string]bool
+	if IsMulticast(data) && !IsBroadcast(data) {
+		members = b.snoop.Members(data)
+	}
+	// writes out of the lock, so a slow device never stalls others.
+	b.lock.RLock()
+	dests := make([]Taper, 0, len(b.devices))
 	for _, dst := range b.devices {
-		if src == dst {
+		if src == dst || b.Isolated(src, dst) {
+			continue
+		}
+		if members != nil && !members[dst.Name()] {
 			continue
 		}
+		dests = append(dests, dst)
+	}
+	b.lock.RUnlock()
+	for _, dst := range dests {
 		_, err = dst.InRead(data)
 	}
 	return err
@@ -260,6 +339,10 @@ func (b *VirtualBridge) Unicast(m *Framer) bool {
 
 	if l := b.FindDest(index); l != nil {
 		dst := l.Device
+		if b.Isolated(src, dst) {
+			libol.Debug("VirtualBridge.Unicast: %s to %s isolated", src, dst)
+			return true
+		}
 		if dst != src {
 			if _, err := dst.InRead(data); err != nil {
 				libol.Debug("VirtualBridge.Unicast: %s %s", dst, err)
@@ -10,5 +10,10 @@ type Bridger interface {
 	DelSlave(dev Taper) error
 	Input(m *Framer) error
 	SetTimeout(value int)
+	SetIsolation(enable bool, uplinks []string)
+	SetStorm(port, bridge StormLimit)
+	StormSts() []StormSts
+	SetSnooping(enable bool, timeout int)
+	McastMembers() []McastMember
 	Mtu() int
 }
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/libol"
+	"net"
+	"sync"
+	"time"
+)
+
+type McastMember struct {
+	Group  string `json:"group"`
+	Device string `json:"device"`
+	Uptime int64  `json:"uptime"`
+}
+
+// Snooping learns IGMP joins and leaves, and maintains the multicast
+// group membership on each port.
+//TODO MLD snooping once IPv6 is supported.
+type Snooping struct {
+	lock    sync.RWMutex
+	name    string
+	enable  bool
+	timeout int64
+	groups  map[string]map[string]int64 // group -> port -> hit time.
+	routers map[string]int64            // port -> hit time of query.
+}
+
+func NewSnooping(name string) *Snooping {
+	return &Snooping{
+		name:    name,
+		timeout: 260,
+		groups:  make(map[string]map[string]int64, 1024),
+		routers: make(map[string]int64, 32),
+	}
+}
+
+func (s *Snooping) SetEnable(enable bool, timeout int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.enable = enable
+	if timeout > 0 {
+		s.timeout = int64(timeout)
+	}
+}
+
+func (s *Snooping) Enabled() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.enable
+}
+
+// decodeIp4 returns ipv4 header and its payload from an ethernet frame.
+func decodeIp4(data []byte) (*libol.Ipv4, []byte) {
+	if len(data) < 14 || binary.BigEndian.Uint16(data[12:14]) != libol.EthIp4 {
+		return nil, nil
+	}
+	data = data[14:]
+	ip, err := libol.NewIpv4FromFrame(data)
+	if err != nil {
+		return nil, nil
+	}
+	hl := int(ip.HeaderLen) * 4
+	if hl < libol.Ipv4Len || len(data) < hl {
+		return nil, nil
+	}
+	return ip, data[hl:]
+}
+
+func (s *Snooping) join(group []byte, port string) {
+	key := net.IP(group).String()
+	ports, ok := s.groups[key]
+	if !ok {
+		ports = make(map[string]int64, 32)
+		s.groups[key] = ports
+		libol.Info("Snooping.join: %s on %s", key, s.name)
+	}
+	if _, ok := ports[port]; !ok {
+		libol.Debug("Snooping.join: %s to %s", port, key)
+	}
+	ports[port] = time.Now().Unix()
+}
+
+func (s *Snooping) leave(group []byte, port string) {
+	key := net.IP(group).String()
+	if ports, ok := s.groups[key]; ok {
+		libol.Debug("Snooping.leave: %s from %s", port, key)
+		delete(ports, port)
+		if len(ports) == 0 {
+			delete(s.groups, key)
+		}
+	}
+}
+
+// Learn updates membership if the frame is an IGMP message.
+func (s *Snooping) Learn(src Taper, data []byte) {
+	if src == nil || !s.Enabled() {
+		return
+	}
+	ip, payload := decodeIp4(data)
+	if ip == nil || ip.Protocol != libol.IpIgmp {
+		return
+	}
+	igmp, err := libol.NewIgmpFromFrame(payload)
+	if err != nil {
+		libol.Warn("Snooping.Learn: %s", err)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	port := src.Name()
+	switch igmp.Type {
+	case libol.IgmpQuery:
+		s.routers[port] = time.Now().Unix()
+	case libol.IgmpV1Report, libol.IgmpV2Report:
+		s.join(igmp.Group, port)
+	case libol.IgmpV2Leave:
+		s.leave(igmp.Group, port)
+	case libol.IgmpV3Report:
+		for _, r := range igmp.Records {
+			switch r.Type {
+			case libol.IgmpIsExclude, libol.IgmpToExclude:
+				s.join(r.Group, port)
+			case libol.IgmpToInclude, libol.IgmpBlock:
+				// leaves only if changed to include nothing.
+				if r.Sources == 0 {
+					s.leave(r.Group, port)
+				} else if r.Type == libol.IgmpToInclude {
+					s.join(r.Group, port)
+				}
+			case libol.IgmpIsInclude, libol.IgmpAllow:
+				if r.Sources > 0 {
+					s.join(r.Group, port)
+				}
+			}
+		}
+	}
+}
+
+// Members returns ports interested in the multicast frame, or nil if the
+// frame should be flooded.
+func (s *Snooping) Members(data []byte) map[string]bool {
+	if !s.Enabled() {
+		return nil
+	}
+	ip, _ := decodeIp4(data)
+	if ip == nil || ip.Protocol == libol.IpIgmp {
+		return nil
+	}
+	dest := ip.Destination
+	if dest[0] == 224 && dest[1] == 0 && dest[2] == 0 { // link local.
+		return nil
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	members := make(map[string]bool, 32)
+	for port := range s.routers {
+		members[port] = true
+	}
+	if ports, ok := s.groups[net.IP(dest).String()]; ok {
+		for port := range ports {
+			members[port] = true
+		}
+	}
+	return members
+}
+
+func (s *Snooping) Del(port string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.routers, port)
+	for key, ports := range s.groups {
+		delete(ports, port)
+		if len(ports) == 0 {
+			delete(s.groups, key)
+		}
+	}
+}
+
+func (s *Snooping) Expire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now().Unix()
+	for port, t := range s.routers {
+		if now-t > s.timeout {
+			delete(s.routers, port)
+		}
+	}
+	for key, ports := range s.groups {
+		for port, t := range ports {
+			if now-t > s.timeout {
+				libol.Debug("Snooping.Expire: %s from %s", port, key)
+				delete(ports, port)
+			}
+		}
+		if len(ports) == 0 {
+			libol.Info("Snooping.Expire: %s on %s", key, s.name)
+			delete(s.groups, key)
+		}
+	}
+}
+
+func (s *Snooping) List() []McastMember {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	now := time.Now().Unix()
+	members := make([]McastMember, 0, 1024)
+	for key, ports := range s.groups {
+		for port, t := range ports {
+			members = append(members, McastMember{
+				Group:  key,
+				Device: port,
+				Uptime: now - t,
+			})
+		}
+	}
+	return members
+}
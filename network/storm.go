@@ -0,0 +1,180 @@
+package network
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"sync"
+	"time"
+)
+
+// StormLimit is frames per second for each kind, and zero is no limited.
+type StormLimit struct {
+	Broadcast int
+	Multicast int
+	Unknown   int
+}
+
+type StormSts struct {
+	Device    string `json:"device"`
+	Broadcast uint64 `json:"broadcast"`
+	Multicast uint64 `json:"multicast"`
+	Unknown   uint64 `json:"unknown"`
+}
+
+type rateMeter struct {
+	limit   int
+	count   int
+	second  int64
+	dropped uint64
+	storm   bool
+}
+
+// Allow returns whether the frame is allowed in this second, and whether
+// this meter is just entering storm.
+func (r *rateMeter) Allow(now int64) (bool, bool) {
+	if r.limit <= 0 {
+		return true, false
+	}
+	if now != r.second {
+		if r.count <= r.limit {
+			r.storm = false
+		}
+		r.second = now
+		r.count = 0
+	}
+	r.count++
+	if r.count <= r.limit {
+		return true, false
+	}
+	r.dropped++
+	if !r.storm {
+		r.storm = true
+		return false, true
+	}
+	return false, false
+}
+
+type stormMeter struct {
+	broadcast rateMeter
+	multicast rateMeter
+	unknown   rateMeter
+}
+
+func newStormMeter(limit StormLimit) *stormMeter {
+	return &stormMeter{
+		broadcast: rateMeter{limit: limit.Broadcast},
+		multicast: rateMeter{limit: limit.Multicast},
+		unknown:   rateMeter{limit: limit.Unknown},
+	}
+}
+
+func (s *stormMeter) Meter(dest []byte) (*rateMeter, string) {
+	if IsBroadcast(dest) {
+		return &s.broadcast, "broadcast"
+	}
+	if IsMulticast(dest) {
+		return &s.multicast, "multicast"
+	}
+	return &s.unknown, "unknown unicast"
+}
+
+func (s *stormMeter) Sts(name string) StormSts {
+	return StormSts{
+		Device:    name,
+		Broadcast: s.broadcast.dropped,
+		Multicast: s.multicast.dropped,
+		Unknown:   s.unknown.dropped,
+	}
+}
+
+type StormControl struct {
+	lock   sync.Mutex
+	name   string
+	port   StormLimit
+	bridge *stormMeter
+	ports  map[string]*stormMeter
+}
+
+func NewStormControl(name string) *StormControl {
+	return &StormControl{
+		name:   name,
+		bridge: newStormMeter(StormLimit{}),
+		ports:  make(map[string]*stormMeter, 1024),
+	}
+}
+
+func (s *StormControl) SetLimit(port, bridge StormLimit) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.port = port
+	s.bridge = newStormMeter(bridge)
+	s.ports = make(map[string]*stormMeter, 1024)
+}
+
+// Allow returns true if the flooded frame from src is under the limits of
+// both this port and the whole bridge.
+func (s *StormControl) Allow(src Taper, dest []byte) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now().Unix()
+	if src != nil {
+		name := src.Name()
+		port, ok := s.ports[name]
+		if !ok {
+			port = newStormMeter(s.port)
+			s.ports[name] = port
+		}
+		meter, kind := port.Meter(dest)
+		if ok, storm := meter.Allow(now); !ok {
+			if storm {
+				libol.Warn("StormControl.Allow: %s on %s exceeded %s %d/s",
+					name, s.name, kind, meter.limit)
+			}
+			return false
+		}
+	}
+	meter, kind := s.bridge.Meter(dest)
+	if ok, storm := meter.Allow(now); !ok {
+		if storm {
+			libol.Warn("StormControl.Allow: %s exceeded %s %d/s", s.name, kind, meter.limit)
+		}
+		return false
+	}
+	return true
+}
+
+func (s *StormControl) Del(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.ports, name)
+}
+
+func (s *StormControl) Sts() []StormSts {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sts := make([]StormSts, 0, len(s.ports)+1)
+	sts = append(sts, s.bridge.Sts(s.name))
+	for name, port := range s.ports {
+		sts = append(sts, port.Sts(name))
+	}
+	return sts
+}
+
+func IsBroadcast(dest []byte) bool {
+	if len(dest) < 6 {
+		return false
+	}
+	for _, b := range dest[:6] {
+		if b != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func IsMulticast(dest []byte) bool {
+	return len(dest) > 0 && dest[0]&0x01 == 0x01
+}
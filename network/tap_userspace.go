@@ -163,3 +163,8 @@ func (t *UserSpaceTap) Mtu() int {
 func (t *UserSpaceTap) SetMtu(mtu int) {
 	t.ifMtu = mtu
 }
+
+// QueueLen returns frames waiting in the read and write queue.
+func (t *UserSpaceTap) QueueLen() (read, write int) {
+	return len(t.readQueue), len(t.writeQueue)
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/gorilla/mux"
 	"net/http"
@@ -105,6 +106,83 @@ func (h *Http) LoadRouter() {
 			ResponseJson(w, h.pointer.Config())
 		}
 	})
+	router.HandleFunc("/current/log", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJson(w, libol.GetLevels())
+	}).Methods("GET")
+	router.HandleFunc("/current/log", h.SetLog).Methods("PUT")
+	router.HandleFunc("/metrics", h.Metrics)
+}
+
+// SetLog changes the level, format or levels of modules, and a module is
+// removed if its level is negative.
+func (h *Http) SetLog(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Level   *int           `json:"level"`
+		Format  string         `json:"format"`
+		Modules map[string]int `json:"modules"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Format != "" {
+		if err := libol.SetFormat(data.Format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if data.Level != nil {
+		libol.SetLog(*data.Level)
+	}
+	for name, level := range data.Modules {
+		libol.SetModule(name, level)
+	}
+	libol.Info("Http.SetLog %v", libol.GetLevels())
+	ResponseJson(w, libol.GetLevels())
+}
+
+func (h *Http) Metrics(w http.ResponseWriter, r *http.Request) {
+	m := libol.NewMetrics()
+	cfg := h.pointer.Config()
+	m.Add("openlan_point_uptime_seconds", libol.MetricGauge,
+		"Seconds since connected to the switch.", h.pointer.UpTime(),
+		"network", cfg.Network, "alias", cfg.Alias)
+	connected := 0
+	if client := h.pointer.Client(); client != nil {
+		if client.Status() == libol.ClAuth {
+			connected = 1
+		}
+		sts := client.Sts()
+		m.Add("openlan_point_connected", libol.MetricGauge,
+			"Whether authenticated by the switch.", connected,
+			"network", cfg.Network, "alias", cfg.Alias)
+		m.Add("openlan_point_received_bytes_total", libol.MetricCounter,
+			"Bytes received from the switch.", sts.RecvOkay,
+			"network", cfg.Network, "alias", cfg.Alias)
+		m.Add("openlan_point_sent_bytes_total", libol.MetricCounter,
+			"Bytes sent to the switch.", sts.SendOkay,
+			"network", cfg.Network, "alias", cfg.Alias)
+		m.Add("openlan_point_errors_total", libol.MetricCounter,
+			"Errors of sending to the switch.", sts.SendError,
+			"network", cfg.Network, "alias", cfg.Alias)
+		m.Add("openlan_point_dropped_total", libol.MetricCounter,
+			"Frames dropped without connection.", sts.Dropped,
+			"network", cfg.Network, "alias", cfg.Alias)
+	} else {
+		m.Add("openlan_point_connected", libol.MetricGauge,
+			"Whether authenticated by the switch.", connected,
+			"network", cfg.Network, "alias", cfg.Alias)
+	}
+	socket, device := h.pointer.QueueLen()
+	m.Add("openlan_point_queue_length", libol.MetricGauge,
+		"Frames waiting in write queues.", socket,
+		"network", cfg.Network, "alias", cfg.Alias, "queue", "socket")
+	m.Add("openlan_point_queue_length", libol.MetricGauge,
+		"Frames waiting in write queues.", device,
+		"network", cfg.Network, "alias", cfg.Alias, "queue", "device")
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(m.Bytes())
 }
 
 func (h *Http) Start() {
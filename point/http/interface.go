@@ -1,8 +1,14 @@
 package http
 
-import "github.com/danieldin95/openlan-go/main/config"
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+)
 
 type Pointer interface {
 	UUID() string
 	Config() *config.Point
+	Client() libol.SocketClient
+	UpTime() int64
+	QueueLen() (socket, device int)
 }
@@ -11,11 +11,12 @@ import (
 type Point struct {
 	MixPoint
 	// private
-	brName string
-	addr   string
-	routes []*models.Route
-	link   netlink.Link
-	uuid   string
+	brName   string
+	addr     string
+	routes   []*models.Route
+	link     netlink.Link
+	uuid     string
+	resolver *Resolver
 }
 
 func NewPoint(config *config.Point) *Point {
@@ -32,6 +33,8 @@ func (p *Point) Initialize() {
 	p.worker.listener.AddRoutes = p.AddRoutes
 	p.worker.listener.DelRoutes = p.DelRoutes
 	p.worker.listener.OnTap = p.OnTap
+	p.worker.listener.AddDns = p.AddDns
+	p.worker.listener.DelDns = p.DelDns
 	p.MixPoint.Initialize()
 }
 
@@ -177,3 +180,28 @@ func (p *Point) DelRoutes(routes []*models.Route) error {
 	p.routes = nil
 	return nil
 }
+
+func (p *Point) AddDns(servers, search []string) error {
+	if p.link == nil {
+		return nil
+	}
+	if p.resolver == nil {
+		p.resolver = NewResolver(p.link.Attrs().Name)
+	}
+	if err := p.resolver.Apply(servers, search); err != nil {
+		libol.Warn("Point.AddDns: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (p *Point) DelDns() error {
+	if p.resolver == nil {
+		return nil
+	}
+	if err := p.resolver.Restore(); err != nil {
+		libol.Warn("Point.DelDns: %s", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package point
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const resolvConf = "/etc/resolv.conf"
+
+// Resolver applies dns servers and search domains by systemd-resolved on
+// the link if it's running, otherwise by rewriting resolv.conf.
+type Resolver struct {
+	link     string
+	resolved bool
+	backup   []byte
+	written  []byte
+}
+
+func NewResolver(link string) *Resolver {
+	return &Resolver{link: link}
+}
+
+func hasResolved() bool {
+	if _, err := os.Stat("/run/systemd/resolve/stub-resolv.conf"); err != nil {
+		return false
+	}
+	_, err := exec.LookPath("resolvectl")
+	return err == nil
+}
+
+func (r *Resolver) Apply(servers, search []string) error {
+	if hasResolved() {
+		return r.applyResolved(servers, search)
+	}
+	return r.applyConf(servers, search)
+}
+
+func (r *Resolver) applyResolved(servers, search []string) error {
+	if len(servers) > 0 {
+		args := append([]string{"dns", r.link}, servers...)
+		if out, err := exec.Command("resolvectl", args...).CombinedOutput(); err != nil {
+			return libol.NewErr("resolvectl dns: %s", out)
+		}
+	}
+	if len(search) > 0 {
+		args := append([]string{"domain", r.link}, search...)
+		if out, err := exec.Command("resolvectl", args...).CombinedOutput(); err != nil {
+			return libol.NewErr("resolvectl domain: %s", out)
+		}
+	}
+	r.resolved = true
+	libol.Info("Resolver.Apply: %s %s on %s", servers, search, r.link)
+	return nil
+}
+
+func (r *Resolver) applyConf(servers, search []string) error {
+	older, err := ioutil.ReadFile(resolvConf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if r.backup == nil {
+		r.backup = older
+	}
+	// ours firstly, and keep the others of original.
+	var buf bytes.Buffer
+	buf.WriteString("# generated by openlan, restored on exit.\n")
+	domains := append([]string{}, search...)
+	for _, line := range strings.Split(string(r.backup), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && (fields[0] == "search" || fields[0] == "domain") {
+			domains = append(domains, fields[1:]...)
+		}
+	}
+	if len(domains) > 0 {
+		buf.WriteString("search " + strings.Join(domains, " ") + "\n")
+	}
+	for _, server := range servers {
+		buf.WriteString("nameserver " + server + "\n")
+	}
+	for _, line := range strings.Split(string(r.backup), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") {
+			continue
+		}
+		if line != "" {
+			buf.WriteString(line + "\n")
+		}
+	}
+	if err := ioutil.WriteFile(resolvConf, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	r.written = buf.Bytes()
+	libol.Info("Resolver.Apply: %s %s in %s", servers, search, resolvConf)
+	return nil
+}
+
+func (r *Resolver) Restore() error {
+	if r.resolved {
+		r.resolved = false
+		if out, err := exec.Command("resolvectl", "revert", r.link).CombinedOutput(); err != nil {
+			return libol.NewErr("resolvectl revert: %s", out)
+		}
+		libol.Info("Resolver.Restore: %s", r.link)
+		return nil
+	}
+	if r.written == nil {
+		return nil
+	}
+	// not restore if it's changed by others.
+	if now, err := ioutil.ReadFile(resolvConf); err == nil && bytes.Equal(now, r.written) {
+		if err := ioutil.WriteFile(resolvConf, r.backup, 0644); err != nil {
+			return err
+		}
+		libol.Info("Resolver.Restore: %s", resolvConf)
+	} else {
+		libol.Warn("Resolver.Restore: %s changed by others", resolvConf)
+	}
+	r.backup = nil
+	r.written = nil
+	return nil
+}
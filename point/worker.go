@@ -852,6 +852,8 @@ type WorkerListener struct {
 	OnTap     func(w *TapWorker) error
 	AddRoutes func(routes []*models.Route) error
 	DelRoutes func(routes []*models.Route) error
+	AddDns    func(servers, search []string) error
+	DelDns    func() error
 }
 
 type PrefixRule struct {
@@ -1042,6 +1044,17 @@ func (p *Worker) IfName() string {
 	return ""
 }
 
+// QueueLen returns frames waiting to write into the socket and the device.
+func (p *Worker) QueueLen() (socket, device int) {
+	if p.tcpWorker != nil {
+		socket = len(p.tcpWorker.writeQueue)
+	}
+	if p.tapWorker != nil {
+		device = len(p.tapWorker.writeQueue)
+	}
+	return
+}
+
 func (p *Worker) Worker() *SocketWorker {
 	if p.tcpWorker != nil {
 		return p.tcpWorker
@@ -1077,6 +1090,9 @@ func (p *Worker) OnIpAddr(w *SocketWorker, n *models.Network) error {
 	if p.listener.AddRoutes != nil {
 		_ = p.listener.AddRoutes(n.Routes)
 	}
+	if p.listener.AddDns != nil && (len(n.Dns) > 0 || len(n.Search) > 0) {
+		_ = p.listener.AddDns(n.Dns, n.Search)
+	}
 	p.network = n
 
 	// update routes
@@ -1106,6 +1122,9 @@ func (p *Worker) FreeIpAddr() {
 	if p.network == nil {
 		return
 	}
+	if p.listener.DelDns != nil {
+		_ = p.listener.DelDns()
+	}
 	if p.listener.DelRoutes != nil {
 		_ = p.listener.DelRoutes(p.network.Routes)
 	}
@@ -0,0 +1,251 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"sort"
+	"sync"
+	"time"
+)
+
+const usageDays = 366
+
+func usageDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func addUsage(m map[string]*schema.UsageSts, key string, delta *schema.UsageSts) {
+	sts, ok := m[key]
+	if !ok {
+		sts = &schema.UsageSts{}
+		m[key] = sts
+	}
+	sts.RxBytes += delta.RxBytes
+	sts.TxBytes += delta.TxBytes
+	sts.RxFrames += delta.RxFrames
+	sts.TxFrames += delta.TxFrames
+	sts.Errors += delta.Errors
+	sts.Dropped += delta.Dropped
+}
+
+// Accounting rolls up traffic of sessions by network and user, and saves
+// daily totals into the file, so it keeps counting after restart.
+type Accounting struct {
+	lock     sync.Mutex
+	file     string
+	days     map[string]*schema.Usage
+	sessions map[string]libol.ClientSts // client -> last counted.
+	dirty    bool
+	ticker   *time.Ticker
+	done     chan bool
+	started  bool
+}
+
+func NewAccounting(file string) *Accounting {
+	a := &Accounting{
+		file:     file,
+		days:     make(map[string]*schema.Usage, usageDays),
+		sessions: make(map[string]libol.ClientSts, 1024),
+		done:     make(chan bool, 1),
+	}
+	if err := libol.FileExist(file); err == nil {
+		days := make([]*schema.Usage, 0, usageDays)
+		if err := libol.UnmarshalLoad(&days, file); err != nil {
+			libol.Warn("NewAccounting %s", err)
+		}
+		for _, day := range days {
+			if day == nil || day.Date == "" {
+				continue
+			}
+			if day.Networks == nil {
+				day.Networks = make(map[string]*schema.UsageSts, 32)
+			}
+			if day.Users == nil {
+				day.Users = make(map[string]*schema.UsageSts, 128)
+			}
+			a.days[day.Date] = day
+		}
+	}
+	return a
+}
+
+func (a *Accounting) today() *schema.Usage {
+	date := usageDate(time.Now())
+	day, ok := a.days[date]
+	if !ok {
+		day = &schema.Usage{
+			Date:     date,
+			Networks: make(map[string]*schema.UsageSts, 32),
+			Users:    make(map[string]*schema.UsageSts, 128),
+		}
+		a.days[date] = day
+	}
+	return day
+}
+
+// count adds traffic of the point since last counted.
+func (a *Accounting) count(p *models.Point) {
+	if p == nil || p.Client == nil {
+		return
+	}
+	addr := p.Client.Addr()
+	sts := p.Client.Sts()
+	last := a.sessions[addr]
+	a.sessions[addr] = sts
+	delta := &schema.UsageSts{
+		RxBytes:  sts.RecvOkay - last.RecvOkay,
+		TxBytes:  sts.SendOkay - last.SendOkay,
+		RxFrames: sts.RecvFrame - last.RecvFrame,
+		TxFrames: sts.SendFrame - last.SendFrame,
+		Errors:   sts.SendError + sts.RecvError - last.SendError - last.RecvError,
+		Dropped:  sts.Dropped - last.Dropped,
+	}
+	if *delta == (schema.UsageSts{}) {
+		return
+	}
+	day := a.today()
+	addUsage(day.Networks, p.Network, delta)
+	if p.User != "" {
+		addUsage(day.Users, p.User, delta)
+	}
+	a.dirty = true
+}
+
+func (a *Accounting) collect() {
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		a.count(p)
+	}
+}
+
+// prune removes the oldest days out of a year.
+func (a *Accounting) prune() {
+	if len(a.days) <= usageDays {
+		return
+	}
+	dates := make([]string, 0, len(a.days))
+	for date := range a.days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates[:len(dates)-usageDays] {
+		delete(a.days, date)
+	}
+}
+
+func (a *Accounting) save() {
+	if !a.dirty {
+		return
+	}
+	a.prune()
+	if err := libol.MarshalSave(a.list(), a.file, true); err != nil {
+		libol.Error("Accounting.save %s", err)
+		return
+	}
+	a.dirty = false
+}
+
+// OnClientClose counts the rest of traffic before the point is removed.
+func (a *Accounting) OnClientClose(client libol.SocketClient) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.count(storage.Point.Get(client.Addr()))
+	delete(a.sessions, client.Addr())
+}
+
+func (a *Accounting) Start() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.started {
+		return
+	}
+	a.started = true
+	a.ticker = time.NewTicker(10 * time.Second)
+	libol.Go(a.Loop)
+}
+
+func (a *Accounting) Loop() {
+	saved := time.Now()
+	for {
+		select {
+		case <-a.done:
+			return
+		case now := <-a.ticker.C:
+			a.lock.Lock()
+			a.collect()
+			if now.Sub(saved) >= time.Minute {
+				a.save()
+				saved = now
+			}
+			a.lock.Unlock()
+		}
+	}
+}
+
+func (a *Accounting) Stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.started {
+		return
+	}
+	a.started = false
+	a.ticker.Stop()
+	a.done <- true
+	a.collect()
+	a.save()
+}
+
+func copyUsage(day *schema.Usage) schema.Usage {
+	usage := schema.Usage{
+		Date:     day.Date,
+		Networks: make(map[string]*schema.UsageSts, len(day.Networks)),
+		Users:    make(map[string]*schema.UsageSts, len(day.Users)),
+	}
+	for name, sts := range day.Networks {
+		addUsage(usage.Networks, name, sts)
+	}
+	for name, sts := range day.Users {
+		addUsage(usage.Users, name, sts)
+	}
+	return usage
+}
+
+func (a *Accounting) list() []schema.Usage {
+	list := make([]schema.Usage, 0, len(a.days))
+	for _, day := range a.days {
+		list = append(list, copyUsage(day))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Date < list[j].Date
+	})
+	return list
+}
+
+// List returns daily usages with the traffic not yet counted.
+func (a *Accounting) List() []schema.Usage {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.collect()
+	return a.list()
+}
+
+// Get returns the usage of the date, or nil if not found.
+func (a *Accounting) Get(date string) *schema.Usage {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.collect()
+	if day, ok := a.days[date]; ok {
+		usage := copyUsage(day)
+		return &usage
+	}
+	return nil
+}
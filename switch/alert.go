@@ -0,0 +1,386 @@
+package _switch
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	webhookQueueSize = 128
+	webhookMaxDelay  = 60 * time.Second
+	alertRepeat      = 5 * time.Minute
+)
+
+// Webhook posts alerts one by one, and retries with backoff doubled from
+// one second if failed.
+type Webhook struct {
+	cfg    config.Webhook
+	types  map[string]bool
+	client *http.Client
+	queue  chan schema.Event
+	done   chan bool
+}
+
+func NewWebhook(c config.Webhook) *Webhook {
+	w := &Webhook{
+		cfg:   c,
+		types: make(map[string]bool, len(c.Events)),
+		queue: make(chan schema.Event, webhookQueueSize),
+		done:  make(chan bool),
+	}
+	if w.cfg.Retries <= 0 {
+		w.cfg.Retries = 3
+	}
+	if w.cfg.Timeout <= 0 {
+		w.cfg.Timeout = 5
+	}
+	w.client = &http.Client{Timeout: time.Duration(w.cfg.Timeout) * time.Second}
+	for _, t := range c.Events {
+		w.types[t] = true
+	}
+	return w
+}
+
+func (w *Webhook) Match(ev *schema.Event) bool {
+	return len(w.types) == 0 || w.types[ev.Type]
+}
+
+// Sign returns hex of hmac-sha256 of the body by the secret.
+func (w *Webhook) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.cfg.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) post(ev *schema.Event, body []byte) error {
+	req, err := http.NewRequest("POST", w.cfg.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-OpenLAN-Event", ev.Type)
+	if w.cfg.Secret != "" {
+		req.Header.Set("X-OpenLAN-Signature", "sha256="+w.Sign(body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return libol.NewErr("%s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) deliver(ev *schema.Event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		libol.Error("Webhook.deliver %s", err)
+		return
+	}
+	delay := time.Second
+	for i := 0; ; i++ {
+		err := w.post(ev, body)
+		if err == nil {
+			libol.Info("Webhook.deliver %s to %s", ev.Type, w.cfg.Url)
+			return
+		}
+		if i >= w.cfg.Retries {
+			libol.Error("Webhook.deliver %s to %s: %s", ev.Type, w.cfg.Url, err)
+			return
+		}
+		libol.Warn("Webhook.deliver %s to %s: %s, retry in %s", ev.Type, w.cfg.Url, err, delay)
+		select {
+		case <-w.done:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > webhookMaxDelay {
+			delay = webhookMaxDelay
+		}
+	}
+}
+
+// Send queues the alert, and drops it if the queue is full.
+func (w *Webhook) Send(ev schema.Event) {
+	select {
+	case w.queue <- ev:
+	default:
+		libol.Warn("Webhook.Send %s to %s: queue is full", ev.Type, w.cfg.Url)
+	}
+}
+
+func (w *Webhook) Loop() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev := <-w.queue:
+			w.deliver(&ev)
+		}
+	}
+}
+
+func (w *Webhook) Stop() {
+	close(w.done)
+}
+
+// alertState is a point or link down since.
+type alertState struct {
+	network string
+	reason  string
+	since   time.Time
+	alerted bool
+}
+
+// Alerter watches events and states of links, and notifies webhooks if
+// a point or link is offline too long, authentication failures spike,
+// leases are exhausted or firewall failed to apply.
+type Alerter struct {
+	lock     sync.Mutex
+	cfg      config.Alert
+	offline  time.Duration
+	watch    map[string]bool // uuid of points, all if empty.
+	hooks    []*Webhook
+	events   *Events
+	failures func() int
+	failed   int
+	checked  time.Time
+	points   map[string]*alertState
+	links    map[string]*alertState
+	sent     map[string]time.Time // type/network/key -> last sent.
+	done     chan bool
+	started  bool
+}
+
+func NewAlerter(c config.Alert, events *Events, failures func() int) *Alerter {
+	a := &Alerter{
+		cfg:      c,
+		offline:  60 * time.Second,
+		watch:    make(map[string]bool, len(c.Points)),
+		hooks:    make([]*Webhook, 0, len(c.Webhooks)),
+		events:   events,
+		failures: failures,
+		points:   make(map[string]*alertState, 32),
+		links:    make(map[string]*alertState, 32),
+		sent:     make(map[string]time.Time, 32),
+		done:     make(chan bool, 1),
+	}
+	if c.Offline > 0 {
+		a.offline = time.Duration(c.Offline) * time.Second
+	}
+	if a.cfg.Failures <= 0 {
+		a.cfg.Failures = 10
+	}
+	for _, uuid := range c.Points {
+		a.watch[uuid] = true
+	}
+	for _, hc := range c.Webhooks {
+		a.hooks = append(a.hooks, NewWebhook(hc))
+	}
+	return a
+}
+
+func (a *Alerter) notify(ev schema.Event) {
+	ev.Time = time.Now().Unix()
+	libol.Info("Alerter.notify %s %s %s", ev.Type, ev.Key, ev.Reason)
+	for _, w := range a.hooks {
+		if w.Match(&ev) {
+			w.Send(ev)
+		}
+	}
+}
+
+// repeated returns true if the alert is already sent recently, and
+// exhausted leases are repeated in the same network.
+func (a *Alerter) repeated(ev *schema.Event, now time.Time) bool {
+	key := ev.Type + "/" + ev.Network
+	if ev.Type != schema.EventLeaseExhausted {
+		key += "/" + ev.Key
+	}
+	if last, ok := a.sent[key]; ok && now.Sub(last) < alertRepeat {
+		return true
+	}
+	a.sent[key] = now
+	return false
+}
+
+func (a *Alerter) onEvent(ev *schema.Event, now time.Time) {
+	switch ev.Type {
+	case schema.EventPointLogout:
+		if len(a.watch) > 0 && !a.watch[ev.Key] {
+			return
+		}
+		if _, ok := a.points[ev.Key]; !ok {
+			a.points[ev.Key] = &alertState{network: ev.Network, reason: ev.Reason, since: now}
+		}
+	case schema.EventPointLogin:
+		if s, ok := a.points[ev.Key]; ok {
+			if s.alerted {
+				a.notify(schema.Event{
+					Type:    schema.AlertPointOnline,
+					Network: ev.Network,
+					Key:     ev.Key,
+					Reason:  fmt.Sprintf("offline for %ds", int(now.Sub(s.since).Seconds())),
+				})
+			}
+			delete(a.points, ev.Key)
+		}
+	case schema.EventLeaseExhausted, schema.EventFirewallError:
+		if !a.repeated(ev, now) {
+			a.notify(schema.Event{
+				Type:    ev.Type,
+				Network: ev.Network,
+				Key:     ev.Key,
+				Reason:  ev.Reason,
+			})
+		}
+	}
+}
+
+func (a *Alerter) checkPoints(now time.Time) {
+	for uuid, s := range a.points {
+		if s.alerted || now.Sub(s.since) < a.offline {
+			continue
+		}
+		s.alerted = true
+		a.notify(schema.Event{
+			Type:    schema.AlertPointOffline,
+			Network: s.network,
+			Key:     uuid,
+			Reason:  fmt.Sprintf("%s and offline for %ds", s.reason, int(now.Sub(s.since).Seconds())),
+		})
+	}
+}
+
+func (a *Alerter) checkLinks(now time.Time) {
+	seen := make(map[string]bool, len(a.links))
+	for link := range storage.Link.List() {
+		if link == nil {
+			break
+		}
+		key := link.Server
+		seen[key] = true
+		up := link.Client != nil && link.Client.Status() == libol.ClAuth
+		s, ok := a.links[key]
+		if up {
+			if ok && s.alerted {
+				a.notify(schema.Event{
+					Type:    schema.AlertLinkOnline,
+					Network: link.Network,
+					Key:     key,
+					Reason:  fmt.Sprintf("offline for %ds", int(now.Sub(s.since).Seconds())),
+				})
+			}
+			delete(a.links, key)
+			continue
+		}
+		if !ok {
+			s = &alertState{network: link.Network, since: now}
+			a.links[key] = s
+		}
+		if !s.alerted && now.Sub(s.since) >= a.offline {
+			s.alerted = true
+			a.notify(schema.Event{
+				Type:    schema.AlertLinkOffline,
+				Network: link.Network,
+				Key:     key,
+				Reason:  fmt.Sprintf("%s for %ds", link.Status, int(now.Sub(s.since).Seconds())),
+			})
+		}
+	}
+	for key := range a.links {
+		if !seen[key] {
+			delete(a.links, key)
+		}
+	}
+}
+
+// checkFailures alerts if failures of authentication in the last minute
+// reached the threshold.
+func (a *Alerter) checkFailures(now time.Time) {
+	if a.failures == nil || now.Sub(a.checked) < time.Minute {
+		return
+	}
+	failed := a.failures()
+	if !a.checked.IsZero() && failed-a.failed >= a.cfg.Failures {
+		a.notify(schema.Event{
+			Type:   schema.AlertAuthFailures,
+			Key:    "auth",
+			Reason: fmt.Sprintf("%d failures in %ds", failed-a.failed, int(now.Sub(a.checked).Seconds())),
+		})
+	}
+	a.failed, a.checked = failed, now
+}
+
+func (a *Alerter) Start() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.started {
+		return
+	}
+	a.started = true
+	libol.Info("Alerter.Start %d webhooks", len(a.hooks))
+	for _, w := range a.hooks {
+		libol.Go(w.Loop)
+	}
+	libol.Go(a.Loop)
+}
+
+func (a *Alerter) Loop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	id, _, events := a.events.Subscribe(-1)
+	var last uint64
+	for {
+		select {
+		case <-a.done:
+			a.events.Unsubscribe(id)
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// closed if too slow, and replays events missed.
+				var replay []schema.Event
+				id, replay, events = a.events.Subscribe(int64(last))
+				for i := range replay {
+					last = replay[i].Seq
+					a.onEvent(&replay[i], time.Now())
+				}
+				continue
+			}
+			last = ev.Seq
+			a.onEvent(&ev, time.Now())
+		case now := <-ticker.C:
+			a.checkPoints(now)
+			a.checkLinks(now)
+			a.checkFailures(now)
+		}
+	}
+}
+
+func (a *Alerter) Stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.started {
+		return
+	}
+	a.started = false
+	a.done <- true
+	for _, w := range a.hooks {
+		w.Stop()
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Audit struct {
+	Switcher Switcher
+}
+
+func (h Audit) Router(router *mux.Router) {
+	router.HandleFunc("/api/audit", h.List).Methods("GET")
+}
+
+// getTime returns unix seconds of the query in seconds or RFC3339, and
+// zero if not given.
+func getTime(r *http.Request, name string) (int64, error) {
+	value := GetQueryOne(r, name)
+	if value == "" {
+		return 0, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return sec, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func (h Audit) List(w http.ResponseWriter, r *http.Request) {
+	start, err := getTime(r, "start")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := getTime(r, "end")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(GetQueryOne(r, "limit"))
+	records, err := h.Switcher.ListAudit(start, end, GetQueryOne(r, "type"), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ResponseJson(w, records)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Capture struct {
+	Switcher Switcher
+}
+
+func (h Capture) Router(router *mux.Router) {
+	router.HandleFunc("/api/capture", h.List).Methods("GET")
+	router.HandleFunc("/api/capture", h.Add).Methods("POST")
+	router.HandleFunc("/api/capture/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/api/capture/{id}", h.Del).Methods("DELETE")
+	router.HandleFunc("/api/capture/{id}/pcap", h.Pcap).Methods("GET")
+}
+
+func (h Capture) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.ListCapture())
+}
+
+func (h Capture) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if c := h.Switcher.GetCapture(vars["id"]); c != nil {
+		ResponseJson(w, c)
+	} else {
+		http.Error(w, vars["id"], http.StatusNotFound)
+	}
+}
+
+func (h Capture) Add(w http.ResponseWriter, r *http.Request) {
+	c := schema.Capture{}
+	if err := GetData(r, &c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Capture.Add %v", c)
+	c, err := h.Switcher.AddCapture(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseJson(w, c)
+}
+
+func (h Capture) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.Switcher.DelCapture(vars["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (h Capture) Pcap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data, format, err := h.Switcher.CapturePcap(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", vars["id"], format))
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	Switcher Switcher
+}
+
+func (h Event) Router(router *mux.Router) {
+	router.HandleFunc("/api/event", h.List).Methods("GET")
+	router.Handle("/api/events", websocket.Server{Handler: h.Stream})
+}
+
+// eventFilter matches types of events, and a type may be a prefix such as
+// 'point' for 'point.login' and 'point.logout'.
+type eventFilter []string
+
+func newEventFilter(r *http.Request) eventFilter {
+	value := GetQueryOne(r, "type")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+func (f eventFilter) Match(ev *schema.Event) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, t := range f {
+		if ev.Type == t || strings.HasPrefix(ev.Type, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// getSince returns the sequence from query or the header of Last-Event-ID,
+// and -1 if not given.
+func getSince(r *http.Request) int64 {
+	value := GetQueryOne(r, "since")
+	if value == "" {
+		value = r.Header.Get("Last-Event-ID")
+	}
+	if value == "" {
+		return -1
+	}
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || since < 0 {
+		return -1
+	}
+	return since
+}
+
+func (h Event) List(w http.ResponseWriter, r *http.Request) {
+	since := getSince(r)
+	if since < 0 {
+		since = 0
+	}
+	filter := newEventFilter(r)
+	events := make([]schema.Event, 0, 128)
+	for _, ev := range h.Switcher.ListEvent(uint64(since)) {
+		if filter.Match(&ev) {
+			events = append(events, ev)
+		}
+	}
+	ResponseJson(w, events)
+}
+
+// Stream replays events since the sequence given, and then sends new
+// events by messages of json until closed.
+func (h Event) Stream(ws *websocket.Conn) {
+	defer ws.Close()
+
+	req := ws.Request()
+	filter := newEventFilter(req)
+	id, replay, events := h.Switcher.SubscribeEvent(getSince(req))
+	defer h.Switcher.UnsubscribeEvent(id)
+	libol.Info("Event.Stream %s subscribed %d", req.RemoteAddr, id)
+
+	// clear deadlines of the server, and detect closing by reading.
+	_ = ws.SetDeadline(time.Time{})
+	done := make(chan bool)
+	libol.Go(func() {
+		var message string
+		for {
+			if err := websocket.Message.Receive(ws, &message); err != nil {
+				close(done)
+				return
+			}
+		}
+	})
+	send := func(ev *schema.Event) bool {
+		if !filter.Match(ev) {
+			return true
+		}
+		_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := websocket.JSON.Send(ws, ev); err != nil {
+			libol.Warn("Event.Stream %s: %s", req.RemoteAddr, err)
+			return false
+		}
+		return true
+	}
+	for i := range replay {
+		if !send(&replay[i]) {
+			return
+		}
+	}
+	for {
+		select {
+		case <-done:
+			libol.Info("Event.Stream %s closed", req.RemoteAddr)
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if !send(&ev) {
+				return
+			}
+		}
+	}
+}
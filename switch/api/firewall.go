@@ -0,0 +1,83 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type FireWall struct {
+	Switcher Switcher
+}
+
+func (h FireWall) Router(router *mux.Router) {
+	router.HandleFunc("/api/firewall", h.List).Methods("GET")
+	router.HandleFunc("/api/firewall", h.Add).Methods("POST")
+	router.HandleFunc("/api/firewall/{index}", h.Del).Methods("DELETE")
+	router.HandleFunc("/api/firewall/{index}", h.Move).Methods("PUT")
+}
+
+func (h FireWall) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.ListRule())
+}
+
+func (h FireWall) Add(w http.ResponseWriter, r *http.Request) {
+	rule := schema.FireWall{}
+	if err := GetData(r, &rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	index := -1
+	if value := GetQueryOne(r, "index"); value != "" {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		index = i
+	}
+	libol.Info("FireWall.Add %v at %d", rule, index)
+	if err := h.Switcher.AddRule(rule, index); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (h FireWall) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("FireWall.Del %d", index)
+	if err := h.Switcher.DelRule(index); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+// Move changes the position of the rule to the index in body.
+func (h FireWall) Move(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	from, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to := schema.FireWall{}
+	if err := GetData(r, &to); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("FireWall.Move %d to %d", from, to.Index)
+	if err := h.Switcher.MoveRule(from, to.Index); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
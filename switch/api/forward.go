@@ -0,0 +1,52 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type Forward struct {
+	Switcher Switcher
+}
+
+func (h Forward) Router(router *mux.Router) {
+	router.HandleFunc("/api/forward", h.List).Methods("GET")
+	router.HandleFunc("/api/forward", h.Add).Methods("POST")
+	router.HandleFunc("/api/forward/{protocol}/{port}", h.Del).Methods("DELETE")
+}
+
+func (h Forward) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.ListForward())
+}
+
+func (h Forward) Add(w http.ResponseWriter, r *http.Request) {
+	fwd := schema.Forward{}
+	if err := GetData(r, &fwd); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Forward.Add %v", fwd)
+	if err := h.Switcher.AddForward(fwd); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (h Forward) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	port, err := strconv.Atoi(vars["port"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Forward.Del %s:%d", vars["protocol"], port)
+	if err := h.Switcher.DelForward(vars["protocol"], port); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Log struct {
+}
+
+func (h Log) Router(router *mux.Router) {
+	router.HandleFunc("/api/log", h.Get).Methods("GET")
+	router.HandleFunc("/api/log", h.Set).Methods("PUT")
+}
+
+func (h Log) Get(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, libol.GetLevels())
+}
+
+// Set changes the level, format or levels of modules, and a module is
+// removed if its level is negative.
+func (h Log) Set(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Level   *int           `json:"level"`
+		Format  string         `json:"format"`
+		Modules map[string]int `json:"modules"`
+	}{}
+	if err := GetData(r, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Format != "" {
+		if err := libol.SetFormat(data.Format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if data.Level != nil {
+		libol.SetLog(*data.Level)
+	}
+	for name, level := range data.Modules {
+		libol.SetModule(name, level)
+	}
+	libol.Info("Log.Set %v", libol.GetLevels())
+	ResponseJson(w, libol.GetLevels())
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+)
+
+type Metrics struct {
+	Switcher Switcher
+}
+
+func (h Metrics) Router(router *mux.Router) {
+	router.HandleFunc("/metrics", h.Get).Methods("GET")
+}
+
+func (h Metrics) Get(w http.ResponseWriter, r *http.Request) {
+	m := libol.NewMetrics()
+	h.Switcher.Metrics(m)
+
+	points := make([]*models.Point, 0, 1024)
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		points = append(points, p)
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].UUID < points[j].UUID
+	})
+	networks := make(map[string]int, 32)
+	for _, p := range points {
+		networks[p.Network]++
+	}
+	for name, count := range networks {
+		m.Add("openlan_switch_points", libol.MetricGauge,
+			"Points online by network.", count, "network", name)
+	}
+	for _, p := range points {
+		m.Add("openlan_switch_point_received_bytes_total", libol.MetricCounter,
+			"Bytes received from the point.", p.Client.Sts().RecvOkay,
+			"network", p.Network, "point", p.UUID, "alias", p.Alias)
+	}
+	for _, p := range points {
+		m.Add("openlan_switch_point_sent_bytes_total", libol.MetricCounter,
+			"Bytes sent to the point.", p.Client.Sts().SendOkay,
+			"network", p.Network, "point", p.UUID, "alias", p.Alias)
+	}
+	for _, p := range points {
+		m.Add("openlan_switch_point_errors_total", libol.MetricCounter,
+			"Errors of sending to the point.", p.Client.Sts().SendError,
+			"network", p.Network, "point", p.UUID, "alias", p.Alias)
+	}
+
+	counts := map[string]int{}
+	for n := range storage.Neighbor.List() {
+		if n == nil {
+			break
+		}
+		counts["neighbors"]++
+	}
+	for l := range storage.Network.ListLease() {
+		if l == nil {
+			break
+		}
+		counts["leases"]++
+	}
+	for l := range storage.Online.List() {
+		if l == nil {
+			break
+		}
+		counts["online"]++
+	}
+	m.Add("openlan_switch_neighbors", libol.MetricGauge,
+		"Neighbors learned.", counts["neighbors"])
+	m.Add("openlan_switch_leases", libol.MetricGauge,
+		"Addresses leased.", counts["leases"])
+	m.Add("openlan_switch_online_lines", libol.MetricGauge,
+		"Lines in online.", counts["online"])
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(m.Bytes())
+}
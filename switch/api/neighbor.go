@@ -9,10 +9,12 @@ import (
 )
 
 type Neighbor struct {
+	Switcher Switcher
 }
 
 func (h Neighbor) Router(router *mux.Router) {
 	router.HandleFunc("/api/neighbor", h.List).Methods("GET")
+	router.HandleFunc("/api/neighbor/statistics", h.Statistics).Methods("GET")
 }
 
 func (h Neighbor) List(w http.ResponseWriter, r *http.Request) {
@@ -25,3 +27,11 @@ func (h Neighbor) List(w http.ResponseWriter, r *http.Request) {
 	}
 	ResponseJson(w, neighbors)
 }
+
+func (h Neighbor) Statistics(w http.ResponseWriter, r *http.Request) {
+	sts := h.Switcher.ArpSts()
+	if sts == nil {
+		sts = make([]schema.ArpSts, 0)
+	}
+	ResponseJson(w, sts)
+}
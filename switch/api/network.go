@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"github.com/gorilla/mux"
@@ -9,11 +10,15 @@ import (
 )
 
 type Network struct {
+	Switcher Switcher
 }
 
 func (h Network) Router(router *mux.Router) {
 	router.HandleFunc("/api/network", h.List).Methods("GET")
 	router.HandleFunc("/api/network/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/api/network/{id}/storm", h.Storm).Methods("GET")
+	router.HandleFunc("/api/network/{id}/multicast", h.Multicast).Methods("GET")
+	router.HandleFunc("/api/network/{id}/acl", h.Acl).Methods("GET")
 }
 
 func (h Network) List(w http.ResponseWriter, r *http.Request) {
@@ -36,3 +41,32 @@ func (h Network) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, vars["id"], http.StatusNotFound)
 	}
 }
+
+func (h Network) Storm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sts := h.Switcher.StormSts(vars["id"])
+	if sts != nil {
+		ResponseJson(w, sts)
+	} else {
+		http.Error(w, vars["id"], http.StatusNotFound)
+	}
+}
+
+func (h Network) Multicast(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	members := h.Switcher.McastMembers(vars["id"])
+	if members == nil {
+		members = make([]network.McastMember, 0)
+	}
+	ResponseJson(w, members)
+}
+
+func (h Network) Acl(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rules := h.Switcher.AclRules(vars["id"])
+	if rules != nil {
+		ResponseJson(w, rules)
+	} else {
+		http.Error(w, vars["id"], http.StatusNotFound)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"github.com/gorilla/mux"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type OnLine struct {
@@ -15,13 +18,91 @@ func (h OnLine) Router(router *mux.Router) {
 	router.HandleFunc("/api/online", h.List).Methods("GET")
 }
 
+// onLineFilter matches lines by query of network, point, user, ip,
+// port, protocol and state.
+type onLineFilter struct {
+	network string
+	point   string
+	user    string
+	ip      string
+	port    string
+	proto   string
+	state   string
+}
+
+func newOnLineFilter(r *http.Request) onLineFilter {
+	return onLineFilter{
+		network: GetQueryOne(r, "network"),
+		point:   GetQueryOne(r, "point"),
+		user:    GetQueryOne(r, "user"),
+		ip:      GetQueryOne(r, "ip"),
+		port:    GetQueryOne(r, "port"),
+		proto:   strings.ToLower(GetQueryOne(r, "protocol")),
+		state:   strings.ToUpper(GetQueryOne(r, "state")),
+	}
+}
+
+func (f onLineFilter) Match(l schema.OnLine) bool {
+	if f.network != "" && f.network != l.Network {
+		return false
+	}
+	if f.point != "" && f.point != l.Point {
+		return false
+	}
+	if f.user != "" && f.user != l.User {
+		return false
+	}
+	if f.ip != "" && f.ip != l.IpSource && f.ip != l.IpDest {
+		return false
+	}
+	if f.port != "" {
+		port := strconv.Itoa(int(l.PortSource))
+		dport := strconv.Itoa(int(l.PortDest))
+		if f.port != port && f.port != dport {
+			return false
+		}
+	}
+	if f.proto != "" && f.proto != strings.ToLower(l.IpProto) {
+		return false
+	}
+	if f.state != "" && f.state != l.State {
+		return false
+	}
+	return true
+}
+
+// sortOnLine sorts lines by bytes, packets or age in descending, and by
+// idle in ascending as default.
+func sortOnLine(lines []schema.OnLine, by string) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		switch by {
+		case "bytes":
+			return a.Bytes+a.RxBytes > b.Bytes+b.RxBytes
+		case "packets":
+			return a.Packets+a.RxPackets > b.Packets+b.RxPackets
+		case "age":
+			return a.Age > b.Age
+		default:
+			return a.Uptime < b.Uptime
+		}
+	})
+}
+
 func (h OnLine) List(w http.ResponseWriter, r *http.Request) {
+	filter := newOnLineFilter(r)
 	nets := make([]schema.OnLine, 0, 1024)
 	for u := range storage.Online.List() {
 		if u == nil {
 			break
 		}
-		nets = append(nets, models.NewOnLineSchema(u))
+		if l := models.NewOnLineSchema(u); filter.Match(l) {
+			nets = append(nets, l)
+		}
+	}
+	sortOnLine(nets, GetQueryOne(r, "sort"))
+	if limit, err := strconv.Atoi(GetQueryOne(r, "limit")); err == nil && limit >= 0 && limit < len(nets) {
+		nets = nets[:limit]
 	}
 	ResponseJson(w, nets)
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/network"
 	"github.com/danieldin95/openlan-go/switch/schema"
 )
 
@@ -14,6 +15,35 @@ type Switcher interface {
 	DelLink(tenant, addr string)
 	Config() *config.Switch
 	Server() libol.SocketServer
+	ArpSts() []schema.ArpSts
+	StormSts(name string) []network.StormSts
+	McastMembers(name string) []network.McastMember
+	AclRules(name string) []schema.AclRule
+	Metrics(m *libol.Metrics)
+	ListRule() []schema.FireWall
+	AddRule(rule schema.FireWall, index int) error
+	DelRule(index int) error
+	MoveRule(from, to int) error
+	ListForward() []schema.Forward
+	AddForward(fwd schema.Forward) error
+	DelForward(protocol string, port int) error
+	ListUsage() []schema.Usage
+	GetUsage(date string) *schema.Usage
+	ListCapture() []schema.Capture
+	GetCapture(id string) *schema.Capture
+	AddCapture(c schema.Capture) (schema.Capture, error)
+	DelCapture(id string) error
+	CapturePcap(id string) ([]byte, string, error)
+	HookSts() []schema.HookSts
+	ListTrace() []schema.Trace
+	GetTrace(id string) *schema.Trace
+	AddTrace(t schema.Trace) (schema.Trace, error)
+	DelTrace(id string) error
+	ListEvent(since uint64) []schema.Event
+	SubscribeEvent(since int64) (int, []schema.Event, <-chan schema.Event)
+	UnsubscribeEvent(id int)
+	AddAudit(record schema.Audit)
+	ListAudit(start, end int64, kind string, limit int) ([]schema.Audit, error)
 }
 
 func NewWorkerSchema(s Switcher) schema.Worker {
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Trace struct {
+	Switcher Switcher
+}
+
+func (h Trace) Router(router *mux.Router) {
+	router.HandleFunc("/api/hook", h.Hooks).Methods("GET")
+	router.HandleFunc("/api/trace", h.List).Methods("GET")
+	router.HandleFunc("/api/trace", h.Add).Methods("POST")
+	router.HandleFunc("/api/trace/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/api/trace/{id}", h.Del).Methods("DELETE")
+}
+
+func (h Trace) Hooks(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.HookSts())
+}
+
+func (h Trace) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.ListTrace())
+}
+
+func (h Trace) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if t := h.Switcher.GetTrace(vars["id"]); t != nil {
+		ResponseJson(w, t)
+	} else {
+		http.Error(w, vars["id"], http.StatusNotFound)
+	}
+}
+
+func (h Trace) Add(w http.ResponseWriter, r *http.Request) {
+	t := schema.Trace{}
+	if err := GetData(r, &t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Trace.Add %s:%s by '%s'", t.Network, t.Point, t.Filter)
+	t, err := h.Switcher.AddTrace(t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseJson(w, t)
+}
+
+func (h Trace) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.Switcher.DelTrace(vars["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
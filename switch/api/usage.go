@@ -0,0 +1,28 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Usage struct {
+	Switcher Switcher
+}
+
+func (h Usage) Router(router *mux.Router) {
+	router.HandleFunc("/api/usage", h.List).Methods("GET")
+	router.HandleFunc("/api/usage/{date}", h.Get).Methods("GET")
+}
+
+func (h Usage) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.Switcher.ListUsage())
+}
+
+func (h Usage) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if usage := h.Switcher.GetUsage(vars["date"]); usage != nil {
+		ResponseJson(w, usage)
+	} else {
+		http.Error(w, vars["date"], http.StatusNotFound)
+	}
+}
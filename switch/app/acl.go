@@ -0,0 +1,328 @@
+package app
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+type aclRule struct {
+	config.AclRule
+	users   map[string]bool
+	srcMac  net.HardwareAddr
+	dstMac  net.HardwareAddr
+	source  *net.IPNet
+	dest    *net.IPNet
+	proto   int // -1 is any.
+	srcPort [2]uint16
+	dstPort [2]uint16
+	hits    uint64
+}
+
+func parsePort(value string) ([2]uint16, error) {
+	if value == "" {
+		return [2]uint16{0, 0xffff}, nil
+	}
+	values := strings.SplitN(value, "-", 2)
+	if len(values) == 1 {
+		values = append(values, values[0])
+	}
+	min, err := strconv.ParseUint(values[0], 10, 16)
+	if err != nil {
+		return [2]uint16{}, libol.NewErr("invalid port %s", value)
+	}
+	max, err := strconv.ParseUint(values[1], 10, 16)
+	if err != nil || max < min {
+		return [2]uint16{}, libol.NewErr("invalid port %s", value)
+	}
+	return [2]uint16{uint16(min), uint16(max)}, nil
+}
+
+func parseProto(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "", "any":
+		return -1, nil
+	case "icmp":
+		return libol.IpIcmp, nil
+	case "tcp":
+		return libol.IpTcp, nil
+	case "udp":
+		return libol.IpUdp, nil
+	}
+	proto, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, libol.NewErr("invalid protocol %s", value)
+	}
+	return int(proto), nil
+}
+
+func parsePrefix(value string) (*net.IPNet, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.Contains(value, "/") {
+		value += "/32"
+	}
+	_, prefix, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, libol.NewErr("invalid prefix %s", value)
+	}
+	return prefix, nil
+}
+
+func parseMac(value string) (net.HardwareAddr, error) {
+	if value == "" {
+		return nil, nil
+	}
+	return net.ParseMAC(value)
+}
+
+func newAclRule(c config.AclRule, groups map[string][]string) (*aclRule, error) {
+	var err error
+
+	r := &aclRule{AclRule: c}
+	switch c.Action {
+	case "allow", "deny", "log":
+	default:
+		return nil, libol.NewErr("invalid action %s", c.Action)
+	}
+	if len(c.Users) > 0 || len(c.Groups) > 0 {
+		r.users = make(map[string]bool, 32)
+		for _, user := range c.Users {
+			r.users[user] = true
+		}
+		for _, group := range c.Groups {
+			users, ok := groups[group]
+			if !ok {
+				return nil, libol.NewErr("group %s not found", group)
+			}
+			for _, user := range users {
+				r.users[user] = true
+			}
+		}
+	}
+	if r.srcMac, err = parseMac(c.SrcMac); err != nil {
+		return nil, err
+	}
+	if r.dstMac, err = parseMac(c.DstMac); err != nil {
+		return nil, err
+	}
+	if r.source, err = parsePrefix(c.Source); err != nil {
+		return nil, err
+	}
+	if r.dest, err = parsePrefix(c.Dest); err != nil {
+		return nil, err
+	}
+	if r.proto, err = parseProto(c.Proto); err != nil {
+		return nil, err
+	}
+	if r.srcPort, err = parsePort(c.SrcPort); err != nil {
+		return nil, err
+	}
+	if r.dstPort, err = parsePort(c.DstPort); err != nil {
+		return nil, err
+	}
+	if (c.SrcPort != "" || c.DstPort != "") && r.proto != libol.IpTcp && r.proto != libol.IpUdp {
+		return nil, libol.NewErr("ports need tcp or udp")
+	}
+	return r, nil
+}
+
+// aclFrame is the decoded headers of a frame.
+type aclFrame struct {
+	eth     *libol.Ether
+	vlan    int
+	ip      *libol.Ipv4
+	srcPort uint16
+	dstPort uint16
+}
+
+func newAclFrame(data []byte) (*aclFrame, error) {
+	eth, err := libol.NewEtherFromFrame(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &aclFrame{eth: eth}
+	data = data[eth.Len:]
+	ethType := eth.Type
+	if eth.IsVlan() {
+		vlan, err := libol.NewVlanFromFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		f.vlan = int(vlan.Vid)
+		ethType = vlan.Pro
+		data = data[vlan.Len:]
+	}
+	if ethType != libol.EthIp4 {
+		return f, nil
+	}
+	ip, err := libol.NewIpv4FromFrame(data)
+	if err != nil {
+		return f, nil
+	}
+	f.ip = ip
+	hl := int(ip.HeaderLen) * 4
+	if ip.Offset != 0 || hl < libol.Ipv4Len || hl > len(data) {
+		return f, nil
+	}
+	data = data[hl:]
+	switch ip.Protocol {
+	case libol.IpTcp:
+		if tcp, err := libol.NewTcpFromFrame(data); err == nil {
+			f.srcPort, f.dstPort = tcp.Source, tcp.Destination
+		}
+	case libol.IpUdp:
+		if udp, err := libol.NewUdpFromFrame(data); err == nil {
+			f.srcPort, f.dstPort = udp.Source, udp.Destination
+		}
+	}
+	return f, nil
+}
+
+func (r *aclRule) Match(user string, f *aclFrame) bool {
+	if r.users != nil && !r.users[user] {
+		return false
+	}
+	if r.srcMac != nil && !bytes.Equal(r.srcMac, f.eth.Src) {
+		return false
+	}
+	if r.dstMac != nil && !bytes.Equal(r.dstMac, f.eth.Dst) {
+		return false
+	}
+	if r.Vlan != 0 && r.Vlan != f.vlan {
+		return false
+	}
+	if r.source == nil && r.dest == nil && r.proto < 0 {
+		return true
+	}
+	if f.ip == nil {
+		return false
+	}
+	if r.source != nil && !r.source.Contains(f.ip.Source) {
+		return false
+	}
+	if r.dest != nil && !r.dest.Contains(f.ip.Destination) {
+		return false
+	}
+	if r.proto >= 0 && r.proto != int(f.ip.Protocol) {
+		return false
+	}
+	if r.SrcPort != "" && (f.srcPort < r.srcPort[0] || f.srcPort > r.srcPort[1]) {
+		return false
+	}
+	if r.DstPort != "" && (f.dstPort < r.dstPort[0] || f.dstPort > r.dstPort[1]) {
+		return false
+	}
+	return true
+}
+
+// Acl filters frames from points on networks with acl enabled, and it
+// works even without the kernel bridge.
+type Acl struct {
+	rules   map[string][]*aclRule // network -> rules.
+	dropped uint64
+	master  Master
+}
+
+func NewAcl(m Master, c config.Switch) (a *Acl) {
+	a = &Acl{
+		rules:  make(map[string][]*aclRule, 32),
+		master: m,
+	}
+	for _, nCfg := range c.Network {
+		if nCfg.Acl == nil || !nCfg.Acl.Enable {
+			continue
+		}
+		rules := make([]*aclRule, 0, len(nCfg.Acl.Rules))
+		for i, rc := range nCfg.Acl.Rules {
+			r, err := newAclRule(rc, nCfg.Acl.Groups)
+			if err != nil {
+				libol.Error("NewAcl %s rule %d: %s", nCfg.Name, i, err)
+				continue
+			}
+			rules = append(rules, r)
+		}
+		a.rules[nCfg.Name] = rules
+	}
+	return
+}
+
+func (a *Acl) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
+	libol.Log("Acl.OnFrame %s.", frame)
+	if frame.IsControl() {
+		return nil
+	}
+	point, ok := client.Private().(*models.Point)
+	if !ok || point == nil {
+		return nil
+	}
+	rules, ok := a.rules[point.Network]
+	if !ok || len(rules) == 0 {
+		return nil
+	}
+	f, err := newAclFrame(frame.Data())
+	if err != nil {
+		libol.Warn("Acl.OnFrame %s", err)
+		return err
+	}
+	for i, r := range rules {
+		if !r.Match(point.User, f) {
+			continue
+		}
+		atomic.AddUint64(&r.hits, 1)
+		switch r.Action {
+		case "log":
+			libol.Info("Acl.OnFrame %s rule %d(%s) on %s", point.Network, i, r.Name, client)
+		case "deny":
+			atomic.AddUint64(&a.dropped, 1)
+			return NewDropped("denied by acl %d", i)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (a *Acl) Enabled(network string) bool {
+	_, ok := a.rules[network]
+	return ok
+}
+
+// Rules returns rules of the network with hits, or nil if not found.
+func (a *Acl) Rules(network string) []schema.AclRule {
+	rules, ok := a.rules[network]
+	if !ok {
+		return nil
+	}
+	sts := make([]schema.AclRule, 0, len(rules))
+	for i, r := range rules {
+		sts = append(sts, schema.AclRule{
+			Index:   i,
+			Name:    r.Name,
+			Users:   r.Users,
+			Groups:  r.Groups,
+			SrcMac:  r.SrcMac,
+			DstMac:  r.DstMac,
+			Vlan:    r.Vlan,
+			Source:  r.Source,
+			Dest:    r.Dest,
+			Proto:   r.Proto,
+			SrcPort: r.SrcPort,
+			DstPort: r.DstPort,
+			Action:  r.Action,
+			Hits:    atomic.LoadUint64(&r.hits),
+		})
+	}
+	return sts
+}
+
+func (a *Acl) Stats() (dropped uint64) {
+	return atomic.LoadUint64(&a.dropped)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newUdpFrame(ipDst []byte, port uint16) *libol.FrameMessage {
+	eth := libol.NewEtherIP4()
+	eth.Dst = libol.BROADED
+	eth.Src = []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x01}
+	ip := libol.NewIpv4()
+	ip.Protocol = libol.IpUdp
+	ip.Source = []byte{192, 168, 1, 10}
+	ip.Destination = ipDst
+	udp := libol.NewUdp()
+	udp.Source = 40000
+	udp.Destination = port
+	data := append(eth.Encode(), ip.Encode()...)
+	data = append(data, udp.Encode()...)
+	return libol.NewFrameMessage(data)
+}
+
+func TestAcl_OnFrame(t *testing.T) {
+	c := config.Switch{
+		Network: []*config.Network{
+			{Name: "acl", Acl: &config.Acl{
+				Enable: true,
+				Groups: map[string][]string{"ops": {"hi@acl"}},
+				Rules: []config.AclRule{
+					{Groups: []string{"ops"}, Action: "allow"},
+					{Proto: "udp", DstPort: "50-60", Action: "log"},
+					{Dest: "192.168.1.0/24", Proto: "udp", DstPort: "53", Action: "deny"},
+					{Proto: "udp", SrcPort: "abc", Action: "deny"},
+				},
+			}},
+		},
+	}
+	a := NewAcl(nil, c)
+	assert.Equal(t, 3, len(a.Rules("acl")), "skip invalid rule.")
+
+	client := &fakeClient{addr: "1.1.1.1:1"}
+	client.private = &models.Point{UUID: "acl-uuid", Network: "acl", User: "guest@acl"}
+	err := a.OnFrame(client, newUdpFrame([]byte{192, 168, 1, 1}, 53))
+	assert.True(t, IsDropped(err), "denied port.")
+	err = a.OnFrame(client, newUdpFrame([]byte{192, 168, 1, 1}, 67))
+	assert.Nil(t, err, "other port.")
+	err = a.OnFrame(client, newUdpFrame([]byte{192, 168, 2, 1}, 53))
+	assert.Nil(t, err, "other prefix.")
+
+	client.private = &models.Point{UUID: "acl-uuid", Network: "acl", User: "hi@acl"}
+	err = a.OnFrame(client, newUdpFrame([]byte{192, 168, 1, 1}, 53))
+	assert.Nil(t, err, "allowed group.")
+
+	rules := a.Rules("acl")
+	assert.Equal(t, uint64(1), rules[0].Hits)
+	assert.Equal(t, uint64(2), rules[1].Hits)
+	assert.Equal(t, uint64(1), rules[2].Hits)
+	assert.Equal(t, uint64(1), a.Stats())
+}
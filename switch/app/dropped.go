@@ -0,0 +1,22 @@
+package app
+
+import "fmt"
+
+// Dropped is returned by a hook when the frame is consumed or filtered by
+// it, and the frame should not be forwarded to the device anymore.
+type Dropped struct {
+	Reason string
+}
+
+func NewDropped(format string, v ...interface{}) error {
+	return &Dropped{Reason: fmt.Sprintf(format, v...)}
+}
+
+func (d *Dropped) Error() string {
+	return d.Reason
+}
+
+func IsDropped(err error) bool {
+	_, ok := err.(*Dropped)
+	return ok
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"sync"
+	"time"
+)
+
+type binding struct {
+	hwAddrs []net.HardwareAddr
+	dropped uint64
+	alerted int64
+}
+
+// SourceGuard drops frames from a point not using the ethernet addresses
+// bound to its session or the ip address leased to it.
+type SourceGuard struct {
+	lock     sync.RWMutex
+	guards   map[string]*config.SourceGuard
+	bindings map[string]*binding
+	dropped  uint64
+	master   Master
+}
+
+func NewSourceGuard(m Master, c config.Switch) (g *SourceGuard) {
+	g = &SourceGuard{
+		guards:   make(map[string]*config.SourceGuard, 32),
+		bindings: make(map[string]*binding, 1024),
+		master:   m,
+	}
+	for _, nCfg := range c.Network {
+		if nCfg.Guard != nil && nCfg.Guard.Enable {
+			g.guards[nCfg.Name] = nCfg.Guard
+		}
+	}
+	return
+}
+
+func (g *SourceGuard) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
+	libol.Log("SourceGuard.OnFrame %s.", frame)
+	if frame.IsControl() {
+		return nil
+	}
+	point, ok := client.Private().(*models.Point)
+	if !ok || point == nil {
+		return nil
+	}
+	guard, ok := g.guards[point.Network]
+	if !ok {
+		return nil
+	}
+	data := frame.Data()
+	eth, err := libol.NewEtherFromFrame(data)
+	if err != nil {
+		libol.Warn("SourceGuard.OnFrame %s", err)
+		return err
+	}
+	if reason := g.check(client, point, guard, eth, data[eth.Len:]); reason != "" {
+		g.drop(client, reason)
+		return NewDropped("spoofed %s", reason)
+	}
+	return nil
+}
+
+// check returns the reason if the frame is spoofed.
+func (g *SourceGuard) check(client libol.SocketClient, point *models.Point,
+	guard *config.SourceGuard, eth *libol.Ether, data []byte) string {
+	if !g.bind(client, guard, eth.Src) {
+		return "ethernet " + net.HardwareAddr(eth.Src).String()
+	}
+	var source []byte
+	if eth.IsArp() {
+		arp, err := libol.NewArpFromFrame(data)
+		if err != nil {
+			return ""
+		}
+		if !bytes.Equal(arp.SHwAddr, eth.Src) {
+			return "arp " + net.HardwareAddr(arp.SHwAddr).String()
+		}
+		source = arp.SIpAddr
+	} else if eth.IsIP4() {
+		ip, err := libol.NewIpv4FromFrame(data)
+		if err != nil {
+			return ""
+		}
+		source = ip.Source
+	} else {
+		return ""
+	}
+	lease := storage.Network.GetAddr(point.UUID)
+	if lease == "" || net.IP(source).Equal(net.IPv4zero) {
+		return ""
+	}
+	if addr := net.IP(source).String(); addr != lease {
+		return "address " + addr
+	}
+	return ""
+}
+
+// bind returns true if hwAddr is bound to the client, and binds it if the
+// client has not reached the maximum number of bindings.
+func (g *SourceGuard) bind(client libol.SocketClient, guard *config.SourceGuard, hwAddr []byte) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	b, ok := g.bindings[client.Addr()]
+	if !ok {
+		b = &binding{hwAddrs: make([]net.HardwareAddr, 0, guard.MaxHwAddr)}
+		g.bindings[client.Addr()] = b
+	}
+	for _, addr := range b.hwAddrs {
+		if bytes.Equal(addr, hwAddr) {
+			return true
+		}
+	}
+	if len(b.hwAddrs) >= guard.MaxHwAddr {
+		return false
+	}
+	addr := make(net.HardwareAddr, 6)
+	copy(addr, hwAddr)
+	b.hwAddrs = append(b.hwAddrs, addr)
+	libol.Info("SourceGuard.bind: %s on %s", addr, client)
+	return true
+}
+
+func (g *SourceGuard) drop(client libol.SocketClient, reason string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.dropped++
+	b, ok := g.bindings[client.Addr()]
+	if !ok {
+		return
+	}
+	b.dropped++
+	now := time.Now().Unix()
+	if now-b.alerted >= 60 { // alert once a minute.
+		b.alerted = now
+		libol.Warn("SourceGuard.drop: %s spoofed %s, total %d", client, reason, b.dropped)
+	}
+}
+
+func (g *SourceGuard) OnClientClose(client libol.SocketClient) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.bindings, client.Addr())
+}
+
+func (g *SourceGuard) Enabled(network string) bool {
+	_, ok := g.guards[network]
+	return ok
+}
+
+func (g *SourceGuard) Stats() (dropped uint64) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.dropped
+}
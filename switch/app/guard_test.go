@@ -0,0 +1,67 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeClient struct {
+	libol.SocketClient
+	addr    string
+	private interface{}
+	written [][]byte
+}
+
+func (c *fakeClient) Addr() string             { return c.addr }
+func (c *fakeClient) String() string           { return c.addr }
+func (c *fakeClient) Private() interface{}     { return c.private }
+func (c *fakeClient) SetPrivate(v interface{}) { c.private = v }
+func (c *fakeClient) WriteMsg(data []byte) error {
+	c.written = append(c.written, data)
+	return nil
+}
+
+func newIp4Frame(src []byte, ipSrc []byte) *libol.FrameMessage {
+	eth := libol.NewEtherIP4()
+	eth.Dst = libol.BROADED
+	eth.Src = src
+	ip := libol.NewIpv4()
+	ip.Source = ipSrc
+	ip.Destination = []byte{192, 168, 1, 255}
+	data := append(eth.Encode(), ip.Encode()...)
+	return libol.NewFrameMessage(data)
+}
+
+func TestSourceGuard_OnFrame(t *testing.T) {
+	c := config.Switch{
+		Network: []*config.Network{
+			{Name: "guard", Guard: &config.SourceGuard{Enable: true, MaxHwAddr: 1}},
+		},
+	}
+	g := NewSourceGuard(nil, c)
+	client := &fakeClient{addr: "1.1.1.1:1"}
+	client.private = &models.Point{UUID: "guard-uuid", Network: "guard"}
+	storage.Network.AddUsedAddr("guard-uuid", "192.168.1.10")
+	defer storage.Network.FreeAddr("guard-uuid")
+
+	hw1 := []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x01}
+	hw2 := []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x02}
+
+	err := g.OnFrame(client, newIp4Frame(hw1, []byte{192, 168, 1, 10}))
+	assert.Nil(t, err, "leased address.")
+	err = g.OnFrame(client, newIp4Frame(hw1, []byte{0, 0, 0, 0}))
+	assert.Nil(t, err, "unspecified address.")
+	err = g.OnFrame(client, newIp4Frame(hw1, []byte{192, 168, 1, 11}))
+	assert.True(t, IsDropped(err), "spoofed address.")
+	err = g.OnFrame(client, newIp4Frame(hw2, []byte{192, 168, 1, 10}))
+	assert.True(t, IsDropped(err), "spoofed ethernet.")
+	assert.Equal(t, uint64(2), g.Stats(), "be the same.")
+
+	g.OnClientClose(client)
+	err = g.OnFrame(client, newIp4Frame(hw2, []byte{192, 168, 1, 10}))
+	assert.Nil(t, err, "bound again.")
+}
@@ -9,5 +9,5 @@ type Master interface {
 	ReadTap(dev network.Taper, readAt func(p []byte) error)
 	NewTap(tenant string) (network.Taper, error)
 	UUID() string
-	OffClient(client libol.SocketClient)
+	OffClient(client libol.SocketClient, reason string)
 }
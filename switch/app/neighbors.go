@@ -1,11 +1,14 @@
 package app
 
 import (
+	"bytes"
 	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/danieldin95/openlan-go/libol"
@@ -15,18 +18,36 @@ type Neighbors struct {
 	lock      sync.RWMutex
 	neighbors map[string]*models.Neighbor
 	master    Master
+	suppress  map[string]bool
+	guard     map[string]bool
+	timeout   int64
+	aging     int64 // seconds to remove neighbors not hit.
+	swept     int64
+	warned    map[string]int64 // ip/mac -> last warned of conflict.
+	sts       map[string]*schema.ArpSts
 }
 
 func NewNeighbors(m Master, c config.Switch) (e *Neighbors) {
 	e = &Neighbors{
 		neighbors: make(map[string]*models.Neighbor, 1024*10),
 		master:    m,
+		suppress:  make(map[string]bool, 32),
+		guard:     make(map[string]bool, 32),
+		timeout:   5 * 60,
+		aging:     15 * 60,
+		warned:    make(map[string]int64, 32),
+		sts:       make(map[string]*schema.ArpSts, 32),
+	}
+	for _, nCfg := range c.Network {
+		e.suppress[nCfg.Name] = nCfg.ArpSuppress
+		e.guard[nCfg.Name] = nCfg.Guard != nil && nCfg.Guard.Enable
 	}
 	return
 }
 
 func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
 	libol.Log("Neighbors.OnFrame %s.", frame)
+	e.expire(time.Now().Unix())
 	if frame.IsControl() {
 		return nil
 	}
@@ -54,20 +75,98 @@ func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage
 			n := models.NewNeighbor(arp.SHwAddr, arp.SIpAddr, client)
 			e.AddNeighbor(n)
 		}
+		if arp.OpCode == libol.ArpRequest && e.Suppress(client, arp) {
+			return NewDropped("arp suppressed")
+		}
 	}
 	return nil
 }
 
+func getNetwork(client libol.SocketClient) string {
+	if client == nil {
+		return ""
+	}
+	if point, ok := client.Private().(*models.Point); ok && point != nil {
+		return point.Network
+	}
+	return ""
+}
+
+func (e *Neighbors) getSts(network string) *schema.ArpSts {
+	sts, ok := e.sts[network]
+	if !ok {
+		sts = &schema.ArpSts{Network: network}
+		e.sts[network] = sts
+	}
+	return sts
+}
+
+// Suppress replies the arp request on behalf of the target if the binding
+// is already learned in the same network, and returns true if replied.
+func (e *Neighbors) Suppress(client libol.SocketClient, arp *libol.Arp) bool {
+	network := getNetwork(client)
+	if network == "" || !e.suppress[network] {
+		return false
+	}
+	if bytes.Equal(arp.SIpAddr, arp.TIpAddr) { // gratuitous arp.
+		return false
+	}
+
+	e.lock.Lock()
+	sts := e.getSts(network)
+	n, ok := e.neighbors[net.IP(arp.TIpAddr).String()]
+	if !ok || n.Client == client || getNetwork(n.Client) != network ||
+		time.Now().Unix()-n.HitTime > e.timeout {
+		sts.Flooded++
+		e.lock.Unlock()
+		return false
+	}
+	sts.Suppressed++
+	hwAddr := n.HwAddr
+	e.lock.Unlock()
+
+	eth := libol.NewEther(libol.EthArp)
+	eth.Dst = arp.SHwAddr
+	eth.Src = hwAddr
+	reply := libol.NewArp()
+	reply.OpCode = libol.ArpReply
+	reply.SHwAddr = hwAddr
+	reply.SIpAddr = arp.TIpAddr
+	reply.THwAddr = arp.SHwAddr
+	reply.TIpAddr = arp.SIpAddr
+
+	buffer := make([]byte, 0, 64)
+	buffer = append(buffer, eth.Encode()...)
+	buffer = append(buffer, reply.Encode()...)
+	libol.Debug("Neighbors.Suppress: %s is at %s for %s", net.IP(arp.TIpAddr), hwAddr, client)
+	if err := client.WriteMsg(buffer); err != nil {
+		libol.Error("Neighbors.Suppress: %s", err)
+	}
+	return true
+}
+
 func (e *Neighbors) AddNeighbor(neb *models.Neighbor) {
 	e.lock.Lock()
 	defer e.lock.Unlock()
 
 	if n, ok := e.neighbors[neb.IpAddr.String()]; ok {
+		if n.Client != neb.Client {
+			e.warnConflict(n, neb)
+			// keep the older if it's still online with source guard.
+			if e.guard[getNetwork(n.Client)] && storage.Point.Get(n.Client.Addr()) != nil {
+				return
+			}
+		}
 		libol.Log("Neighbors.AddNeighbor: update %s.", neb)
+		moved := n.Client != neb.Client || !bytes.Equal(n.HwAddr, neb.HwAddr)
 		n.IpAddr = neb.IpAddr
+		n.HwAddr = neb.HwAddr
 		n.Client = neb.Client
 		n.HitTime = time.Now().Unix()
 		storage.Neighbor.Update(neb)
+		if moved {
+			storage.Neighbor.Move(n)
+		}
 	} else {
 		libol.Log("Neighbors.AddNeighbor: new %s.", neb)
 		e.neighbors[neb.IpAddr.String()] = neb
@@ -76,8 +175,8 @@ func (e *Neighbors) AddNeighbor(neb *models.Neighbor) {
 }
 
 func (e *Neighbors) DelNeighbor(ipAddr net.IP) {
-	e.lock.RLock()
-	defer e.lock.RUnlock()
+	e.lock.Lock()
+	defer e.lock.Unlock()
 
 	libol.Info("Neighbors.DelNeighbor %s.", ipAddr)
 	if n := e.neighbors[ipAddr.String()]; n != nil {
@@ -86,7 +185,57 @@ func (e *Neighbors) DelNeighbor(ipAddr net.IP) {
 	}
 }
 
+// warnConflict warns once per ip and mac in a minute.
+func (e *Neighbors) warnConflict(older, newer *models.Neighbor) {
+	key := newer.IpAddr.String() + "/" + newer.HwAddr.String()
+	now := time.Now().Unix()
+	if last, ok := e.warned[key]; ok && now-last < 60 {
+		return
+	}
+	e.warned[key] = now
+	libol.Warn("Neighbors.AddNeighbor: %s conflict on %s and %s",
+		newer.IpAddr, older.Client, newer.Client)
+}
+
+// expire removes neighbors not hit out of aging, and sweeps at most once
+// per 10 seconds.
+func (e *Neighbors) expire(now int64) {
+	if now-atomic.LoadInt64(&e.swept) < 10 {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if now-e.swept < 10 {
+		return
+	}
+	atomic.StoreInt64(&e.swept, now)
+	for key, last := range e.warned {
+		if now-last >= 60 {
+			delete(e.warned, key)
+		}
+	}
+	for key, n := range e.neighbors {
+		if now-n.HitTime > e.aging {
+			libol.Info("Neighbors.expire %s.", n)
+			storage.Neighbor.Del(key)
+			delete(e.neighbors, key)
+		}
+	}
+}
+
 func (e *Neighbors) OnClientClose(client libol.SocketClient) {
 	//TODO
 	libol.Info("Neighbors.OnClientClose %s.", client)
 }
+
+func (e *Neighbors) Stats() []schema.ArpSts {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	sts := make([]schema.ArpSts, 0, len(e.sts))
+	for _, s := range e.sts {
+		sts = append(sts, *s)
+	}
+	return sts
+}
@@ -7,24 +7,79 @@ import (
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"sync"
-	"time"
 )
 
+const (
+	tcpFin = 0x01
+	tcpSyn = 0x02
+	tcpRst = 0x04
+	tcpAck = 0x10
+)
+
+// nextState returns the tcp state after the flags, which are from
+// destination if reply.
+func nextState(state uint32, flags uint8, reply bool) uint32 {
+	switch {
+	case flags&tcpRst != 0:
+		return models.TcpClosed
+	case flags&tcpSyn != 0 && flags&tcpAck == 0 && !reply:
+		return models.TcpSynSent
+	case flags&tcpSyn != 0 && flags&tcpAck != 0 && reply:
+		if state == models.TcpSynSent {
+			return models.TcpSynRecv
+		}
+	case flags&tcpFin != 0:
+		if state == models.TcpFinWait {
+			return models.TcpClosed
+		}
+		if state < models.TcpFinWait {
+			return models.TcpFinWait
+		}
+	case flags&tcpAck != 0:
+		if state == models.TcpNone || state == models.TcpSynRecv {
+			return models.TcpEstablished
+		}
+	}
+	return state
+}
+
+// flowTable is flows of a network in order of last hit.
+type flowTable struct {
+	lines map[string]*list.Element
+	list  *list.List
+}
+
+func (t *flowTable) remove(e *list.Element) {
+	line := e.Value.(*models.Line)
+	t.list.Remove(e)
+	delete(t.lines, line.String())
+	storage.Online.Del(line.String())
+}
+
+// Online tracks flows of each network with counters in both directions,
+// and flows are removed if idle or the table is full.
 type Online struct {
-	max      int
-	lock     sync.RWMutex
-	lines    map[string]*models.Line
-	lineList *list.List
-	master   Master
+	lock    sync.Mutex
+	size    int
+	timeout int64
+	tables  map[string]*flowTable
+	master  Master
 }
 
 func NewOnline(m Master, c config.Switch) (o *Online) {
-	max := 64
 	o = &Online{
-		max:      max,
-		lines:    make(map[string]*models.Line, max),
-		lineList: list.New(),
-		master:   m,
+		size:    1024,
+		timeout: 5 * 60,
+		tables:  make(map[string]*flowTable, 32),
+		master:  m,
+	}
+	if c.Online != nil {
+		if c.Online.Size > 0 {
+			o.size = c.Online.Size
+		}
+		if c.Online.Timeout > 0 {
+			o.timeout = int64(c.Online.Timeout)
+		}
 	}
 	return
 }
@@ -41,63 +96,100 @@ func (o *Online) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		return err
 	}
 	data = data[eth.Len:]
-	if eth.IsIP4() {
-		ip, err := libol.NewIpv4FromFrame(data)
+	if !eth.IsIP4() {
+		return nil
+	}
+	ip, err := libol.NewIpv4FromFrame(data)
+	if err != nil {
+		libol.Warn("Online.OnFrame %s", err)
+		return err
+	}
+	data = data[ip.Len:]
+	line := models.NewLine(eth.Type)
+	line.IpSource = ip.Source
+	line.IpDest = ip.Destination
+	line.IpProtocol = ip.Protocol
+	if point, ok := client.Private().(*models.Point); ok && point != nil {
+		line.Network = point.Network
+		line.Point = point.UUID
+		line.User = point.User
+	}
+	flags := uint8(0)
+	switch ip.Protocol {
+	case libol.IpTcp:
+		tcp, err := libol.NewTcpFromFrame(data)
 		if err != nil {
 			libol.Warn("Online.OnFrame %s", err)
-			return err
+			return nil
 		}
-		data = data[ip.Len:]
-		line := models.NewLine(eth.Type)
-		line.IpSource = ip.Source
-		line.IpDest = ip.Destination
-		line.IpProtocol = ip.Protocol
-		switch ip.Protocol {
-		case libol.IpTcp:
-			tcp, err := libol.NewTcpFromFrame(data)
-			if err != nil {
-				libol.Warn("Online.OnFrame %s", err)
-			}
-			line.PortDest = tcp.Destination
-			line.PortSource = tcp.Source
-		case libol.IpUdp:
-			udp, err := libol.NewUdpFromFrame(data)
-			if err != nil {
-				libol.Warn("Online.OnFrame %s", err)
-			}
-			line.PortDest = udp.Destination
-			line.PortSource = udp.Source
-		default:
-			line.PortDest = 0
-			line.PortSource = 0
+		line.PortDest = tcp.Destination
+		line.PortSource = tcp.Source
+		flags = tcp.ControlBits
+	case libol.IpUdp:
+		udp, err := libol.NewUdpFromFrame(data)
+		if err != nil {
+			libol.Warn("Online.OnFrame %s", err)
+			return nil
 		}
-		o.AddLine(line)
+		line.PortDest = udp.Destination
+		line.PortSource = udp.Source
 	}
+	o.AddLine(line, len(frame.Data()), flags)
 	return nil
 }
 
-func (o *Online) AddLine(line *models.Line) {
+// expire removes flows idle out of timeout, and closed flows after a while.
+func (o *Online) expire() {
+	for _, t := range o.tables {
+		for e := t.list.Front(); e != nil; e = t.list.Front() {
+			line := e.Value.(*models.Line)
+			idle := line.UpTime()
+			if idle <= o.timeout && (line.GetState() != models.TcpClosed || idle <= 10) {
+				break
+			}
+			t.remove(e)
+		}
+	}
+}
+
+// AddLine counts the frame of size to its flow, and creates the flow if
+// not found in both directions.
+func (o *Online) AddLine(line *models.Line, size int, flags uint8) {
 	o.lock.Lock()
 	defer o.lock.Unlock()
 
 	libol.Log("Online.AddLine %s", line)
-	libol.Log("Online.AddLine %d", o.lineList.Len())
-	find, ok := o.lines[line.String()]
+	o.expire()
+	t, ok := o.tables[line.Network]
 	if !ok {
-		if o.lineList.Len() >= o.max {
-			if e := o.lineList.Front(); e != nil {
-				lastLine := e.Value.(*models.Line)
-
-				o.lineList.Remove(e)
-				delete(o.lines, lastLine.String())
-				storage.Online.Del(lastLine.String())
+		t = &flowTable{
+			lines: make(map[string]*list.Element, o.size),
+			list:  list.New(),
+		}
+		o.tables[line.Network] = t
+	}
+	reply := false
+	e, ok := t.lines[line.String()]
+	if !ok {
+		if e, ok = t.lines[line.Reply()]; ok {
+			reply = true
+		}
+	}
+	if !ok {
+		if t.list.Len() >= o.size {
+			if front := t.list.Front(); front != nil {
+				t.remove(front)
 			}
 		}
-		o.lineList.PushBack(line)
-		o.lines[line.String()] = line
+		e = t.list.PushBack(line)
+		t.lines[line.String()] = e
 		storage.Online.Add(line)
-	} else if find != nil {
-		find.HitTime = time.Now().Unix()
-		storage.Online.Update(find)
+	} else {
+		t.list.MoveToBack(e)
+	}
+	find := e.Value.(*models.Line)
+	find.Hit(size, reply)
+	if find.IpProtocol == libol.IpTcp {
+		find.SetState(nextState(find.GetState(), flags, reply))
 	}
 }
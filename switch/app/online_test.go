@@ -0,0 +1,45 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func newTcpLine(src, dst string, sport, dport uint16) *models.Line {
+	line := models.NewLine(libol.EthIp4)
+	line.Network = "flow"
+	line.IpProtocol = libol.IpTcp
+	line.IpSource = net.ParseIP(src).To4()
+	line.IpDest = net.ParseIP(dst).To4()
+	line.PortSource = sport
+	line.PortDest = dport
+	return line
+}
+
+func TestOnline_AddLine(t *testing.T) {
+	o := NewOnline(nil, config.Switch{Online: &config.OnLine{Size: 2}})
+
+	o.AddLine(newTcpLine("10.0.0.1", "10.0.0.2", 40000, 22), 60, tcpSyn)
+	o.AddLine(newTcpLine("10.0.0.2", "10.0.0.1", 22, 40000), 60, tcpSyn|tcpAck)
+	o.AddLine(newTcpLine("10.0.0.1", "10.0.0.2", 40000, 22), 100, tcpAck)
+	line := storage.Online.Get(newTcpLine("10.0.0.1", "10.0.0.2", 40000, 22).String())
+	assert.NotNil(t, line)
+	assert.Equal(t, uint64(2), line.Packets)
+	assert.Equal(t, uint64(160), line.Bytes)
+	assert.Equal(t, uint64(1), line.ReplyPackets)
+	assert.Equal(t, "ESTABLISHED", line.StateStr())
+	assert.Nil(t, storage.Online.Get(newTcpLine("10.0.0.2", "10.0.0.1", 22, 40000).String()))
+
+	o.AddLine(newTcpLine("10.0.0.1", "10.0.0.3", 40001, 80), 60, tcpSyn)
+	o.AddLine(newTcpLine("10.0.0.1", "10.0.0.4", 40002, 80), 60, tcpSyn)
+	assert.Nil(t, storage.Online.Get(line.String()), "evicted by size")
+
+	o.AddLine(newTcpLine("10.0.0.4", "10.0.0.1", 80, 40002), 60, tcpRst)
+	line = storage.Online.Get(newTcpLine("10.0.0.1", "10.0.0.4", 40002, 80).String())
+	assert.Equal(t, "CLOSED", line.StateStr())
+}
@@ -83,7 +83,9 @@ func (p *PointAuth) handleLogin(client libol.SocketClient, data string) error {
 		if nowUser.Password == user.Password {
 			p.success++
 			client.SetStatus(libol.ClAuth)
-			libol.Info("PointAuth.handleLogin: %s auth", client.Addr())
+			libol.With("client", client.Addr(), "network", user.Network,
+				"uuid", user.UUID).Info("PointAuth.handleLogin: %s auth", name)
+			user.Name = nowUser.Name
 			_ = p.onAuth(client, user)
 			return nil
 		}
@@ -107,18 +109,21 @@ func (p *PointAuth) onAuth(client libol.SocketClient, user *models.User) error {
 	m.Alias = user.Alias
 	m.UUID = user.UUID
 	m.Network = user.Network
+	m.User = user.Name
 	if m.UUID == "" {
 		m.UUID = user.Alias
 	}
 
 	// free point has same uuid.
 	if om := storage.Point.GetByUUID(m.UUID); om != nil {
-		p.master.OffClient(om.Client)
+		p.master.OffClient(om.Client, "replaced")
 	}
 
 	client.SetPrivate(m)
 	storage.Point.Add(m)
-	libol.Go(func() { p.master.ReadTap(dev, client.WriteMsg) })
+	if dev != nil {
+		libol.Go(func() { p.master.ReadTap(dev, client.WriteMsg) })
+	}
 
 	return nil
 }
@@ -86,12 +86,18 @@ func (r *WithRequest) OnIpAddr(client libol.SocketClient, data string) {
 				IpEnd:   ipStr,
 				Netmask: netmask,
 				Routes:  net.Routes,
+				Dns:     net.Dns,
+				Search:  net.Search,
 			}
 		}
 	} else {
 		ipAddr := strings.SplitN(rcvNet.IfAddr, "/", 2)[0]
 		storage.Network.AddUsedAddr(uuid, ipAddr)
 		resp = rcvNet
+		if net != nil {
+			resp.Dns = net.Dns
+			resp.Search = net.Search
+		}
 	}
 	if resp != nil {
 		libol.Cmd("WithRequest.OnIpAddr: resp %s", resp)
@@ -107,5 +113,5 @@ func (r *WithRequest) OnIpAddr(client libol.SocketClient, data string) {
 
 func (r *WithRequest) OnLeave(client libol.SocketClient, data string) {
 	libol.Info("WithRequest.OnLeave: %s", client.RemoteAddr())
-	r.master.OffClient(client)
+	r.master.OffClient(client, "leave")
 }
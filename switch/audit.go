@@ -0,0 +1,193 @@
+package _switch
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const auditMaxRecords = 10000
+
+// Auditor appends records of actions to a file by lines of json, which is
+// rotated with backups, and records logins and leases from events.
+type Auditor struct {
+	lock    sync.Mutex
+	path    string
+	file    *libol.RotateFile
+	events  *Events
+	done    chan bool
+	started bool
+}
+
+func NewAuditor(c config.Audit, events *Events) *Auditor {
+	a := &Auditor{
+		path:   c.File,
+		events: events,
+		done:   make(chan bool, 1),
+	}
+	file, err := libol.NewRotateFile(a.path)
+	if err != nil {
+		libol.Error("NewAuditor %s", err)
+		return a
+	}
+	size, backups, age := c.MaxSize, c.Backups, c.MaxAge
+	if size <= 0 {
+		size = 16
+	}
+	if backups <= 0 {
+		backups = 8
+	}
+	file.SetLimit(int64(size)<<20, 0, backups, time.Duration(age)*24*time.Hour)
+	a.file = file
+	return a
+}
+
+func (a *Auditor) Add(record schema.Audit) {
+	if record.Time == 0 {
+		record.Time = time.Now().Unix()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.file == nil {
+		return
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		libol.Error("Auditor.Add %s", err)
+	}
+}
+
+func (a *Auditor) onEvent(ev *schema.Event) {
+	switch ev.Type {
+	case schema.EventPointLogin, schema.EventPointLogout,
+		schema.EventLeaseAssign, schema.EventLeaseFree:
+	default:
+		return
+	}
+	record := schema.Audit{
+		Time:    ev.Time,
+		Type:    ev.Type,
+		Network: ev.Network,
+		Target:  ev.Key,
+		Detail:  ev.Reason,
+	}
+	switch data := ev.Data.(type) {
+	case schema.Point:
+		record.Actor = data.User
+		record.Source = data.Address
+		if record.Detail == "" {
+			record.Detail = data.Alias
+		}
+	case schema.Lease:
+		record.Source = data.Client
+		record.Detail = data.Address
+	}
+	a.Add(record)
+}
+
+func (a *Auditor) Start() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.started || a.file == nil {
+		return
+	}
+	a.started = true
+	libol.Info("Auditor.Start %s", a.path)
+	libol.Go(a.Loop)
+}
+
+func (a *Auditor) Loop() {
+	id, _, events := a.events.Subscribe(-1)
+	var last uint64
+	for {
+		select {
+		case <-a.done:
+			a.events.Unsubscribe(id)
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// closed if too slow, and replays events missed.
+				var replay []schema.Event
+				id, replay, events = a.events.Subscribe(int64(last))
+				for i := range replay {
+					last = replay[i].Seq
+					a.onEvent(&replay[i])
+				}
+				continue
+			}
+			last = ev.Seq
+			a.onEvent(&ev)
+		}
+	}
+}
+
+func (a *Auditor) Stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.started {
+		return
+	}
+	a.started = false
+	a.done <- true
+	_ = a.file.Close()
+	a.file = nil
+}
+
+// files returns backups and the file in order of time.
+func (a *Auditor) files() []string {
+	files, _ := filepath.Glob(a.path + ".*")
+	sort.Strings(files)
+	return append(files, a.path)
+}
+
+// List returns records between start and end in unix seconds, and of the
+// type if given. It returns at most limit records from start.
+func (a *Auditor) List(start, end int64, kind string, limit int) ([]schema.Audit, error) {
+	if limit <= 0 || limit > auditMaxRecords {
+		limit = auditMaxRecords
+	}
+	records := make([]schema.Audit, 0, 128)
+	for _, name := range a.files() {
+		if info, err := os.Stat(name); err != nil || info.ModTime().Unix() < start {
+			continue // nothing written since start.
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return records, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			record := schema.Audit{}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue
+			}
+			if record.Time < start || (end > 0 && record.Time > end) {
+				continue
+			}
+			if kind != "" && record.Type != kind {
+				continue
+			}
+			records = append(records, record)
+			if len(records) >= limit {
+				break
+			}
+		}
+		_ = file.Close()
+		if len(records) >= limit {
+			break
+		}
+	}
+	return records, nil
+}
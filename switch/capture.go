@@ -0,0 +1,468 @@
+package _switch
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	captureMaxSessions = 16
+	captureMaxCount    = 10000
+	captureMaxDuration = 600
+	captureMaxBytes    = 64 * 1024 * 1024
+)
+
+// captureFrame is the decoded headers of a frame to filter.
+type captureFrame struct {
+	eth     *libol.Ether
+	ethType uint16
+	vlan    bool
+	source  net.IP
+	dest    net.IP
+	proto   int // -1 if not ip.
+	srcPort uint16
+	dstPort uint16
+}
+
+func newCaptureFrame(data []byte) *captureFrame {
+	eth, err := libol.NewEtherFromFrame(data)
+	if err != nil {
+		return nil
+	}
+	f := &captureFrame{eth: eth, ethType: eth.Type, proto: -1}
+	data = data[eth.Len:]
+	if eth.IsVlan() {
+		vlan, err := libol.NewVlanFromFrame(data)
+		if err != nil {
+			return f
+		}
+		f.vlan = true
+		f.ethType = vlan.Pro
+		data = data[vlan.Len:]
+	}
+	switch f.ethType {
+	case libol.EthArp:
+		if arp, err := libol.NewArpFromFrame(data); err == nil {
+			f.source, f.dest = arp.SIpAddr, arp.TIpAddr
+		}
+	case libol.EthIp4:
+		ip, err := libol.NewIpv4FromFrame(data)
+		if err != nil {
+			return f
+		}
+		f.source, f.dest, f.proto = ip.Source, ip.Destination, int(ip.Protocol)
+		hl := int(ip.HeaderLen) * 4
+		if ip.Offset != 0 || hl < libol.Ipv4Len || hl > len(data) {
+			return f
+		}
+		data = data[hl:]
+		switch ip.Protocol {
+		case libol.IpTcp:
+			if tcp, err := libol.NewTcpFromFrame(data); err == nil {
+				f.srcPort, f.dstPort = tcp.Source, tcp.Destination
+			}
+		case libol.IpUdp:
+			if udp, err := libol.NewUdpFromFrame(data); err == nil {
+				f.srcPort, f.dstPort = udp.Source, udp.Destination
+			}
+		}
+	}
+	return f
+}
+
+// captureTerm is a primitive of filter like tcpdump, such as 'src host
+// 192.168.1.1', 'net 10.0.0.0/8', 'dst port 53', 'ether host <mac>',
+// 'arp', 'tcp' and 'not udp'.
+type captureTerm struct {
+	not    bool
+	dir    string // src/dst or both.
+	kind   string // host/port/ether/proto.
+	prefix *net.IPNet
+	port   uint16
+	mac    net.HardwareAddr
+	proto  string
+}
+
+func (t *captureTerm) matchIp(f *captureFrame) bool {
+	if f.source == nil {
+		return false
+	}
+	src := t.prefix.Contains(f.source)
+	dst := f.dest != nil && t.prefix.Contains(f.dest)
+	switch t.dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	}
+	return src || dst
+}
+
+func (t *captureTerm) matchPort(f *captureFrame) bool {
+	if f.proto != libol.IpTcp && f.proto != libol.IpUdp {
+		return false
+	}
+	switch t.dir {
+	case "src":
+		return f.srcPort == t.port
+	case "dst":
+		return f.dstPort == t.port
+	}
+	return f.srcPort == t.port || f.dstPort == t.port
+}
+
+func (t *captureTerm) matchEther(f *captureFrame) bool {
+	src := bytes.Equal(f.eth.Src, t.mac)
+	dst := bytes.Equal(f.eth.Dst, t.mac)
+	switch t.dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	}
+	return src || dst
+}
+
+func (t *captureTerm) matchProto(f *captureFrame) bool {
+	switch t.proto {
+	case "arp":
+		return f.ethType == libol.EthArp
+	case "ip":
+		return f.ethType == libol.EthIp4
+	case "vlan":
+		return f.vlan
+	case "icmp":
+		return f.proto == libol.IpIcmp
+	case "tcp":
+		return f.proto == libol.IpTcp
+	case "udp":
+		return f.proto == libol.IpUdp
+	}
+	return false
+}
+
+func (t *captureTerm) Match(f *captureFrame) bool {
+	var ok bool
+	switch t.kind {
+	case "host":
+		ok = t.matchIp(f)
+	case "port":
+		ok = t.matchPort(f)
+	case "ether":
+		ok = t.matchEther(f)
+	default:
+		ok = t.matchProto(f)
+	}
+	return ok != t.not
+}
+
+func parseCaptureTerm(words []string) (*captureTerm, error) {
+	t := &captureTerm{}
+	expr := strings.Join(words, " ")
+	if len(words) > 0 && (words[0] == "not" || words[0] == "!") {
+		t.not = true
+		words = words[1:]
+	}
+	if len(words) > 0 && words[0] == "ether" {
+		t.kind = "ether"
+		words = words[1:]
+	}
+	if len(words) > 0 && (words[0] == "src" || words[0] == "dst") {
+		t.dir = words[0]
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return nil, libol.NewErr("invalid filter '%s'", expr)
+	}
+	if t.kind == "ether" {
+		if words[0] == "host" {
+			words = words[1:]
+		}
+		if len(words) != 1 {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		mac, err := net.ParseMAC(words[0])
+		if err != nil {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		t.mac = mac
+		return t, nil
+	}
+	switch words[0] {
+	case "host", "net":
+		if len(words) != 2 {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		value := words[1]
+		if !strings.Contains(value, "/") {
+			value += "/32"
+		}
+		_, prefix, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		t.kind, t.prefix = "host", prefix
+	case "port":
+		if len(words) != 2 {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		port, err := strconv.ParseUint(words[1], 10, 16)
+		if err != nil {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		t.kind, t.port = "port", uint16(port)
+	case "arp", "ip", "vlan", "icmp", "tcp", "udp":
+		if len(words) != 1 || t.dir != "" {
+			return nil, libol.NewErr("invalid filter '%s'", expr)
+		}
+		t.kind, t.proto = "proto", words[0]
+	default:
+		return nil, libol.NewErr("invalid filter '%s'", expr)
+	}
+	return t, nil
+}
+
+// parseCaptureFilter returns terms of the filter joined by 'and'.
+func parseCaptureFilter(filter string) ([]*captureTerm, error) {
+	terms := make([]*captureTerm, 0, 4)
+	words := make([]string, 0, 4)
+	for _, word := range append(strings.Fields(strings.ToLower(filter)), "and") {
+		if word == "or" || word == "||" {
+			return nil, libol.NewErr("'or' is not supported")
+		}
+		if word != "and" && word != "&&" {
+			words = append(words, word)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		// 'udp port 53' is 'udp and port 53'.
+		if len(words) > 1 && (words[0] == "tcp" || words[0] == "udp") {
+			terms = append(terms, &captureTerm{kind: "proto", proto: words[0]})
+			words = words[1:]
+		}
+		t, err := parseCaptureTerm(words)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+		words = words[:0]
+	}
+	return terms, nil
+}
+
+type captureSession struct {
+	cfg     schema.Capture
+	ifName  string
+	terms   []*captureTerm
+	buffer  bytes.Buffer
+	writer  *libol.PcapWriter
+	timer   *time.Timer
+	running bool
+}
+
+func (s *captureSession) Match(network, point, ifName string) bool {
+	if s.cfg.Network != network {
+		return false
+	}
+	if s.cfg.Point == "" {
+		return true
+	}
+	if point != "" {
+		return point == s.cfg.Point
+	}
+	return ifName != "" && ifName == s.ifName
+}
+
+func (s *captureSession) Filter(f *captureFrame) bool {
+	for _, t := range s.terms {
+		if !t.Match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *captureSession) Schema() schema.Capture {
+	c := s.cfg
+	c.Running = s.running
+	return c
+}
+
+// Capturer captures frames from and to points in the pipeline of switch,
+// so it works even if the network has no kernel device.
+type Capturer struct {
+	lock     sync.Mutex
+	sessions map[string]*captureSession
+	active   int32
+}
+
+func NewCapturer() *Capturer {
+	return &Capturer{
+		sessions: make(map[string]*captureSession, captureMaxSessions),
+	}
+}
+
+func (c *Capturer) Add(cfg schema.Capture) (schema.Capture, error) {
+	if cfg.Count <= 0 {
+		cfg.Count = 1000
+	} else if cfg.Count > captureMaxCount {
+		cfg.Count = captureMaxCount
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = 60
+	} else if cfg.Duration > captureMaxDuration {
+		cfg.Duration = captureMaxDuration
+	}
+	if cfg.Format == "" {
+		cfg.Format = "pcap"
+	}
+	if cfg.Format != "pcap" && cfg.Format != "pcapng" {
+		return cfg, libol.NewErr("invalid format %s", cfg.Format)
+	}
+	terms, err := parseCaptureFilter(cfg.Filter)
+	if err != nil {
+		return cfg, err
+	}
+	s := &captureSession{terms: terms, running: true}
+	if cfg.Point != "" {
+		p := storage.Point.GetByUUID(cfg.Point)
+		if p == nil || p.Network != cfg.Network {
+			return cfg, libol.NewErr("point %s not found on %s", cfg.Point, cfg.Network)
+		}
+		if p.Device != nil {
+			s.ifName = p.Device.Name()
+		}
+	}
+	if s.writer, err = libol.NewPcapWriter(&s.buffer, cfg.Format == "pcapng"); err != nil {
+		return cfg, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.sessions) >= captureMaxSessions {
+		return cfg, libol.NewErr("too many captures")
+	}
+	cfg.ID = libol.GenToken(8)
+	cfg.Start = time.Now().Unix()
+	cfg.Captured = 0
+	cfg.Bytes = 0
+	s.cfg = cfg
+	c.sessions[cfg.ID] = s
+	atomic.AddInt32(&c.active, 1)
+	s.timer = time.AfterFunc(time.Duration(cfg.Duration)*time.Second, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.finish(s)
+	})
+	libol.Info("Capturer.Add %s on %s:%s by '%s'", cfg.ID, cfg.Network, cfg.Point, cfg.Filter)
+	return s.Schema(), nil
+}
+
+func (c *Capturer) finish(s *captureSession) {
+	if !s.running {
+		return
+	}
+	s.running = false
+	s.timer.Stop()
+	atomic.AddInt32(&c.active, -1)
+	libol.Info("Capturer.finish %s with %d frames", s.cfg.ID, s.cfg.Captured)
+}
+
+// Capture writes the frame into sessions matched, and point is empty if
+// the frame is to the device.
+func (c *Capturer) Capture(network, point, ifName string, data []byte) {
+	if atomic.LoadInt32(&c.active) == 0 {
+		return
+	}
+	now := time.Now()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var frame *captureFrame
+	for _, s := range c.sessions {
+		if !s.running || !s.Match(network, point, ifName) {
+			continue
+		}
+		if frame == nil {
+			if frame = newCaptureFrame(data); frame == nil {
+				return
+			}
+		}
+		if !s.Filter(frame) {
+			continue
+		}
+		if err := s.writer.WritePacket(now, data); err != nil {
+			libol.Warn("Capturer.Capture %s", err)
+			continue
+		}
+		s.cfg.Captured++
+		s.cfg.Bytes = s.buffer.Len()
+		if s.cfg.Captured >= s.cfg.Count || s.cfg.Bytes >= captureMaxBytes {
+			c.finish(s)
+		}
+	}
+}
+
+func (c *Capturer) List() []schema.Capture {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	list := make([]schema.Capture, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		list = append(list, s.Schema())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start < list[j].Start
+	})
+	return list
+}
+
+func (c *Capturer) Get(id string) *schema.Capture {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s, ok := c.sessions[id]; ok {
+		sc := s.Schema()
+		return &sc
+	}
+	return nil
+}
+
+// Pcap returns frames captured so far in the format of the session.
+func (c *Capturer) Pcap(id string) ([]byte, string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s, ok := c.sessions[id]
+	if !ok {
+		return nil, "", libol.NewErr("capture %s not found", id)
+	}
+	return append([]byte{}, s.buffer.Bytes()...), s.cfg.Format, nil
+}
+
+// Del stops the session and frees frames captured.
+func (c *Capturer) Del(id string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s, ok := c.sessions[id]
+	if !ok {
+		return libol.NewErr("capture %s not found", id)
+	}
+	c.finish(s)
+	delete(c.sessions, id)
+	return nil
+}
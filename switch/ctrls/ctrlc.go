@@ -14,6 +14,7 @@ type CtrlC struct {
 	Password string `json:"password"`
 	Conn     *libctrl.Conn
 	Switcher Switcher
+	OnCmd    func(id string, m libctrl.Message) `json:"-"`
 }
 
 func (cc *CtrlC) Register() {
@@ -50,6 +51,13 @@ func (cc *CtrlC) Open() error {
 	cc.Conn = &libctrl.Conn{
 		Conn: to,
 		Wait: libstar.NewWaitOne(1),
+		Oner: libctrl.ConnOner{
+			CmdCtl: func(con *libctrl.Conn, m libctrl.Message) {
+				if cc.OnCmd != nil {
+					cc.OnCmd(cc.Name, m)
+				}
+			},
+		},
 	}
 	return nil
 }
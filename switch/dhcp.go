@@ -0,0 +1,270 @@
+package _switch
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DhcpServer assigns addresses to devices on the bridge of a network, from
+// the same pool and leases with points.
+type DhcpServer struct {
+	lock    sync.Mutex
+	name    string
+	device  string
+	address net.IP
+	cfg     config.Dhcp
+	conn    *net.UDPConn
+	leases  map[string]int64 // uuid -> expired time.
+	done    chan bool
+}
+
+func NewDhcpServer(c config.Network) *DhcpServer {
+	ifAddr := strings.SplitN(c.Bridge.Address, "/", 2)[0]
+	return &DhcpServer{
+		name:    c.Name,
+		device:  c.Bridge.Name,
+		address: net.ParseIP(ifAddr).To4(),
+		cfg:     *c.Dhcp,
+		leases:  make(map[string]int64, 1024),
+		done:    make(chan bool, 2),
+	}
+}
+
+func (s *DhcpServer) Start() {
+	if s.address == nil {
+		libol.Warn("DhcpServer.Start %s: bridge without address", s.name)
+		return
+	}
+	conn, err := libol.ListenUDPOnDevice(s.device, "0.0.0.0:67")
+	if err != nil {
+		libol.Error("DhcpServer.Start %s: %s", s.name, err)
+		return
+	}
+	s.lock.Lock()
+	s.conn = conn
+	s.lock.Unlock()
+	libol.Info("DhcpServer.Start %s on %s", s.name, s.device)
+	libol.Go(s.Loop)
+	libol.Go(s.Expire)
+}
+
+func (s *DhcpServer) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+	_ = s.conn.Close()
+	s.conn = nil
+	s.done <- true
+}
+
+func (s *DhcpServer) Loop() {
+	data := make([]byte, 1500)
+	for {
+		s.lock.Lock()
+		conn := s.conn
+		s.lock.Unlock()
+		if conn == nil {
+			break
+		}
+		n, _, err := conn.ReadFromUDP(data)
+		if err != nil {
+			libol.Debug("DhcpServer.Loop %s: %s", s.name, err)
+			break
+		}
+		req, err := libol.NewDhcpFromFrame(data[:n])
+		if err != nil {
+			libol.Debug("DhcpServer.Loop %s: %s", s.name, err)
+			continue
+		}
+		if req.Op != libol.DhcpRequest || req.HLen != 6 {
+			continue
+		}
+		s.onRequest(req)
+	}
+	libol.Info("DhcpServer.Loop %s exit", s.name)
+}
+
+func (s *DhcpServer) Expire() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			now := time.Now().Unix()
+			s.lock.Lock()
+			for uuid, t := range s.leases {
+				if now > t {
+					libol.Info("DhcpServer.Expire %s: %s", s.name, uuid)
+					storage.Network.FreeAddr(uuid)
+					delete(s.leases, uuid)
+				}
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+func (s *DhcpServer) setLease(uuid string, timeout int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.leases[uuid] = time.Now().Unix() + int64(timeout)
+}
+
+func (s *DhcpServer) freeLease(uuid string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	storage.Network.FreeAddr(uuid)
+	delete(s.leases, uuid)
+}
+
+func (s *DhcpServer) onRequest(req *libol.Dhcp) {
+	n := storage.Network.Get(s.name)
+	if n == nil {
+		libol.Warn("DhcpServer.onRequest %s: without subnet", s.name)
+		return
+	}
+	uuid := "dhcp:" + s.name + ":" + req.HwAddr().String()
+	switch req.Type() {
+	case libol.DhcpDiscover:
+		ipStr, _ := storage.Network.GetFreeAddr(uuid, n)
+		if ipStr == "" {
+			libol.Warn("DhcpServer.onRequest %s: no free address for %s", s.name, uuid)
+			return
+		}
+		s.lock.Lock()
+		if _, ok := s.leases[uuid]; !ok {
+			s.leases[uuid] = time.Now().Unix() + 60
+		}
+		s.lock.Unlock()
+		s.reply(req, libol.DhcpOffer, net.ParseIP(ipStr), n)
+	case libol.DhcpRequire:
+		if id := req.Option(libol.DhcpOptServerId); id != nil && !s.address.Equal(id) {
+			return // selected another server.
+		}
+		want := net.IP(req.Option(libol.DhcpOptReqAddr))
+		if want == nil {
+			want = req.CIAddr
+		}
+		ipStr, _ := storage.Network.GetFreeAddr(uuid, n)
+		if ipStr == "" || !want.Equal(net.ParseIP(ipStr)) {
+			libol.Info("DhcpServer.onRequest %s: nak %s for %s", s.name, want, uuid)
+			s.reply(req, libol.DhcpNak, nil, n)
+			return
+		}
+		s.setLease(uuid, s.cfg.LeaseTime)
+		libol.Info("DhcpServer.onRequest %s: %s for %s", s.name, ipStr, uuid)
+		s.reply(req, libol.DhcpAck, net.ParseIP(ipStr), n)
+	case libol.DhcpDecline:
+		// hold the address used by others.
+		want := net.IP(req.Option(libol.DhcpOptReqAddr))
+		libol.Warn("DhcpServer.onRequest %s: %s declined by %s", s.name, want, uuid)
+		s.freeLease(uuid)
+		if want != nil {
+			holder := "decline:" + s.name + ":" + want.String()
+			storage.Network.AddUsedAddr(holder, want.String())
+			s.setLease(holder, s.cfg.LeaseTime)
+		}
+	case libol.DhcpRelease:
+		libol.Info("DhcpServer.onRequest %s: release %s", s.name, uuid)
+		s.freeLease(uuid)
+	case libol.DhcpInform:
+		s.reply(req, libol.DhcpAck, nil, n)
+	}
+}
+
+// routes returns the classless static routes, and includes default route
+// if enabled since the router option is ignored by clients if it's present.
+func (s *DhcpServer) routes(n *models.Network) []byte {
+	value := make([]byte, 0, 64)
+	for _, rt := range n.Routes {
+		_, prefix, err := net.ParseCIDR(rt.Prefix)
+		nexthop := net.ParseIP(rt.NextHop).To4()
+		if err != nil || nexthop == nil || prefix.IP.To4() == nil {
+			continue
+		}
+		size, _ := prefix.Mask.Size()
+		value = append(value, uint8(size))
+		value = append(value, prefix.IP.To4()[:(size+7)/8]...)
+		value = append(value, nexthop...)
+	}
+	if s.cfg.DefaultRoute {
+		value = append(value, 0)
+		value = append(value, s.address...)
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
+
+func (s *DhcpServer) reply(req *libol.Dhcp, typ uint8, yiAddr net.IP, n *models.Network) {
+	resp := libol.NewDhcp()
+	resp.Op = libol.DhcpReply
+	resp.HType = req.HType
+	resp.HLen = req.HLen
+	resp.Xid = req.Xid
+	resp.Flags = req.Flags
+	copy(resp.GIAddr, req.GIAddr)
+	copy(resp.CHAddr, req.CHAddr)
+	if yiAddr != nil {
+		copy(resp.YIAddr, yiAddr.To4())
+	}
+	if typ == libol.DhcpAck && yiAddr == nil { // inform.
+		copy(resp.CIAddr, req.CIAddr)
+	}
+	resp.SetOption(libol.DhcpOptType, []byte{typ})
+	resp.SetOption(libol.DhcpOptServerId, s.address)
+	if typ != libol.DhcpNak {
+		if yiAddr != nil {
+			lease := make([]byte, 4)
+			binary.BigEndian.PutUint32(lease, uint32(s.cfg.LeaseTime))
+			resp.SetOption(libol.DhcpOptLeaseTime, lease)
+		}
+		if mask := net.ParseIP(n.Netmask).To4(); mask != nil {
+			resp.SetOption(libol.DhcpOptNetmask, mask)
+		}
+		if s.cfg.Router {
+			resp.SetOption(libol.DhcpOptRouter, s.address)
+		}
+		if len(s.cfg.Dns) > 0 {
+			dns := make([]byte, 0, 4*len(s.cfg.Dns))
+			for _, addr := range s.cfg.Dns {
+				if ip := net.ParseIP(addr).To4(); ip != nil {
+					dns = append(dns, ip...)
+				}
+			}
+			resp.SetOption(libol.DhcpOptDns, dns)
+		}
+		if routes := s.routes(n); routes != nil {
+			resp.SetOption(libol.DhcpOptRoutes, routes)
+		}
+	}
+
+	dest := &net.UDPAddr{IP: net.IPv4bcast, Port: 68}
+	if !net.IP(req.GIAddr).Equal(net.IPv4zero) {
+		dest = &net.UDPAddr{IP: req.GIAddr, Port: 67}
+	} else if typ == libol.DhcpAck && yiAddr == nil {
+		dest = &net.UDPAddr{IP: req.CIAddr, Port: 68}
+	}
+	s.lock.Lock()
+	conn := s.conn
+	s.lock.Unlock()
+	if conn == nil {
+		return
+	}
+	if _, err := conn.WriteToUDP(resp.Encode(), dest); err != nil {
+		libol.Error("DhcpServer.reply %s: %s", s.name, err)
+	}
+}
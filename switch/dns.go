@@ -0,0 +1,230 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsMaxForwards is the max of queries forwarding to upstream at once.
+const dnsMaxForwards = 64
+
+// DnsServer answers <alias>.<network>.<domain> of points in its network on
+// the bridge address, and forwards others to upstream. Only queries from
+// the subnet of the network are served.
+type DnsServer struct {
+	lock     sync.Mutex
+	name     string
+	address  string
+	subnet   *net.IPNet
+	domain   string
+	upstream []string
+	conn     *net.UDPConn
+	forwards chan bool
+}
+
+func NewDnsServer(c config.Network) *DnsServer {
+	upstream := make([]string, 0, len(c.Dns.Upstream))
+	for _, addr := range c.Dns.Upstream {
+		if !strings.Contains(addr, ":") {
+			addr += ":53"
+		}
+		upstream = append(upstream, addr)
+	}
+	_, subnet, _ := net.ParseCIDR(c.Bridge.Address)
+	return &DnsServer{
+		name:     strings.ToLower(c.Name),
+		address:  strings.SplitN(c.Bridge.Address, "/", 2)[0],
+		subnet:   subnet,
+		domain:   "." + strings.ToLower(strings.Trim(c.Dns.Domain, ".")) + ".",
+		upstream: upstream,
+		forwards: make(chan bool, dnsMaxForwards),
+	}
+}
+
+func (d *DnsServer) Start() {
+	if d.address == "" || d.subnet == nil {
+		libol.Warn("DnsServer.Start %s: bridge without address", d.name)
+		return
+	}
+	addr, err := net.ResolveUDPAddr("udp4", d.address+":53")
+	if err != nil {
+		libol.Error("DnsServer.Start %s: %s", d.name, err)
+		return
+	}
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		libol.Error("DnsServer.Start %s: %s", d.name, err)
+		return
+	}
+	d.lock.Lock()
+	d.conn = conn
+	d.lock.Unlock()
+	libol.Info("DnsServer.Start %s on %s", d.name, addr)
+	libol.Go(d.Loop)
+}
+
+func (d *DnsServer) Stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.conn != nil {
+		_ = d.conn.Close()
+		d.conn = nil
+	}
+}
+
+func (d *DnsServer) Loop() {
+	for {
+		d.lock.Lock()
+		conn := d.conn
+		d.lock.Unlock()
+		if conn == nil {
+			break
+		}
+		data := make([]byte, 1500)
+		n, from, err := conn.ReadFromUDP(data)
+		if err != nil {
+			libol.Debug("DnsServer.Loop %s: %s", d.name, err)
+			break
+		}
+		if !d.subnet.Contains(from.IP) {
+			libol.Debug("DnsServer.Loop %s: ignore %s", d.name, from)
+			continue
+		}
+		req, err := libol.NewDnsFromFrame(data[:n])
+		if err != nil || req.Flags&libol.DnsFlagQR != 0 || len(req.Questions) != 1 {
+			continue
+		}
+		if resp := d.Answer(req); resp != nil {
+			_, _ = conn.WriteToUDP(resp.Encode(), from)
+			continue
+		}
+		select {
+		case d.forwards <- true:
+			libol.Go(func() {
+				d.forward(conn, data[:n], from)
+				<-d.forwards
+			})
+		default:
+			libol.Debug("DnsServer.Loop %s: too many forwards", d.name)
+			_, _ = conn.WriteToUDP(req.Reply(libol.DnsServFail).Encode(), from)
+		}
+	}
+	libol.Info("DnsServer.Loop %s exit", d.name)
+}
+
+// findPoint returns the point has alias on the network, and its lease.
+func findPoint(alias, network string) (*models.Point, string) {
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		if strings.ToLower(p.Alias) == alias && strings.ToLower(p.Network) == network {
+			if addr := storage.Network.GetAddr(p.UUID); addr != "" {
+				return p, addr
+			}
+		}
+	}
+	return nil, ""
+}
+
+// ptrName returns the name of the point has the address in the network.
+func (d *DnsServer) ptrName(addr string) string {
+	ip := net.ParseIP(addr).To4()
+	if ip == nil || d.subnet == nil || !d.subnet.Contains(ip) {
+		return ""
+	}
+	p := storage.Point.GetByUUID(storage.Network.AddrUUID.Get(ip.String()))
+	if p == nil || p.Alias == "" || strings.ToLower(p.Network) != d.name {
+		return ""
+	}
+	return strings.ToLower(p.Alias+"."+p.Network) + d.domain
+}
+
+// Answer returns the response if the question is local, otherwise nil.
+func (d *DnsServer) Answer(req *libol.Dns) *libol.Dns {
+	q := req.Questions[0]
+	name := strings.ToLower(q.Name)
+	if q.Class != libol.DnsClassIn {
+		return nil
+	}
+	if strings.HasSuffix(name, d.domain) {
+		labels := strings.Split(strings.TrimSuffix(name, d.domain), ".")
+		if len(labels) != 2 {
+			return req.Reply(libol.DnsNxDomain)
+		}
+		if labels[1] != d.name {
+			return req.Reply(libol.DnsRefused)
+		}
+		_, addr := findPoint(labels[0], d.name)
+		if addr == "" {
+			return req.Reply(libol.DnsNxDomain)
+		}
+		resp := req.Reply(libol.DnsNoError)
+		if q.Type == libol.DnsTypeA {
+			resp.Answers = append(resp.Answers, libol.DnsRecord{
+				Name:  q.Name,
+				Type:  libol.DnsTypeA,
+				Class: libol.DnsClassIn,
+				Ttl:   60,
+				Data:  net.ParseIP(addr).To4(),
+			})
+		}
+		return resp
+	}
+	if q.Type == libol.DnsTypePtr && strings.HasSuffix(name, ".in-addr.arpa.") {
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa."), ".")
+		if len(labels) != 4 {
+			return nil
+		}
+		addr := labels[3] + "." + labels[2] + "." + labels[1] + "." + labels[0]
+		if ptr := d.ptrName(addr); ptr != "" {
+			resp := req.Reply(libol.DnsNoError)
+			resp.Answers = append(resp.Answers, libol.DnsRecord{
+				Name:  q.Name,
+				Type:  libol.DnsTypePtr,
+				Class: libol.DnsClassIn,
+				Ttl:   60,
+				Data:  libol.EncodeDnsName(ptr),
+			})
+			return resp
+		}
+		if ip := net.ParseIP(addr); ip != nil && d.subnet != nil && d.subnet.Contains(ip) {
+			return req.Reply(libol.DnsNxDomain) // not to leak the subnet.
+		}
+	}
+	return nil
+}
+
+func (d *DnsServer) forward(conn *net.UDPConn, data []byte, from *net.UDPAddr) {
+	for _, server := range d.upstream {
+		up, err := net.DialTimeout("udp", server, 2*time.Second)
+		if err != nil {
+			libol.Debug("DnsServer.forward %s: %s", server, err)
+			continue
+		}
+		_ = up.SetDeadline(time.Now().Add(2 * time.Second))
+		if _, err := up.Write(data); err != nil {
+			_ = up.Close()
+			continue
+		}
+		buffer := make([]byte, 4096)
+		n, err := up.Read(buffer)
+		_ = up.Close()
+		if err != nil {
+			libol.Debug("DnsServer.forward %s: %s", server, err)
+			continue
+		}
+		_, _ = conn.WriteToUDP(buffer[:n], from)
+		return
+	}
+	if req, err := libol.NewDnsFromFrame(data); err == nil {
+		_, _ = conn.WriteToUDP(req.Reply(libol.DnsServFail).Encode(), from)
+	}
+}
@@ -0,0 +1,314 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"sync"
+	"time"
+)
+
+const (
+	eventMaxSize   = 1024
+	eventQueueSize = 256
+)
+
+// Events keeps latest events in a ring to replay, and sends new events to
+// subscribers. A subscriber too slow to receive is closed, and should
+// subscribe again since the last sequence.
+type Events struct {
+	lock      sync.Mutex
+	seq       uint64
+	ring      []schema.Event
+	next      int
+	subs      map[int]chan schema.Event
+	subId     int
+	points    map[string]*models.Point // address -> point logged in.
+	reasons   map[string]string        // address -> reason of logout.
+	neighbors map[string]schema.Neighbor
+	links     map[string]schema.Link
+	leases    map[string]schema.Lease
+}
+
+func NewEvents() *Events {
+	return &Events{
+		ring:      make([]schema.Event, 0, eventMaxSize),
+		subs:      make(map[int]chan schema.Event, 32),
+		points:    make(map[string]*models.Point, 1024),
+		reasons:   make(map[string]string, 32),
+		neighbors: make(map[string]schema.Neighbor, 1024),
+		links:     make(map[string]schema.Link, 32),
+		leases:    make(map[string]schema.Lease, 1024),
+	}
+}
+
+// Register listens changes of points, neighbors, links and leases.
+func (e *Events) Register() {
+	_ = storage.Point.Listen.Add("events", &pointEvents{e: e})
+	_ = storage.Neighbor.Listen.Add("events", &neighborEvents{e: e})
+	_ = storage.Link.Listen.Add("events", &linkEvents{e: e})
+	_ = storage.Network.Listen.Add("events", &leaseEvents{e: e})
+}
+
+func (e *Events) Unregister() {
+	storage.Point.Listen.Del("events")
+	storage.Neighbor.Listen.Del("events")
+	storage.Link.Listen.Del("events")
+	storage.Network.Listen.Del("events")
+}
+
+// Reason saves the reason of logout for the client going to close.
+func (e *Events) Reason(addr, reason string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.reasons[addr] = reason
+}
+
+func (e *Events) publish(ev schema.Event) {
+	e.seq++
+	ev.Seq = e.seq
+	ev.Time = time.Now().Unix()
+	if len(e.ring) < eventMaxSize {
+		e.ring = append(e.ring, ev)
+	} else {
+		e.ring[e.next] = ev
+		e.next = (e.next + 1) % eventMaxSize
+	}
+	libol.Cmd("Events.publish %d %s %s", ev.Seq, ev.Type, ev.Key)
+	for id, c := range e.subs {
+		select {
+		case c <- ev:
+		default:
+			libol.Warn("Events.publish: subscriber %d too slow", id)
+			close(c)
+			delete(e.subs, id)
+		}
+	}
+}
+
+func (e *Events) Publish(ev schema.Event) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.publish(ev)
+}
+
+// list returns events after the sequence in order.
+func (e *Events) list(since uint64) []schema.Event {
+	events := make([]schema.Event, 0, len(e.ring))
+	for i := 0; i < len(e.ring); i++ {
+		ev := e.ring[(e.next+i)%len(e.ring)]
+		if ev.Seq > since {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func (e *Events) List(since uint64) []schema.Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.list(since)
+}
+
+// Subscribe returns events after the sequence to replay and a channel of
+// new events, and nothing to replay if since is negative.
+func (e *Events) Subscribe(since int64) (int, []schema.Event, <-chan schema.Event) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var replay []schema.Event
+	if since >= 0 {
+		replay = e.list(uint64(since))
+	}
+	e.subId++
+	c := make(chan schema.Event, eventQueueSize)
+	e.subs[e.subId] = c
+	return e.subId, replay, c
+}
+
+func (e *Events) Unsubscribe(id int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if c, ok := e.subs[id]; ok {
+		close(c)
+		delete(e.subs, id)
+	}
+}
+
+type pointEvents struct {
+	e *Events
+}
+
+func (p *pointEvents) Add(key string, value interface{}) {
+	obj, ok := value.(*models.Point)
+	if !ok || obj == nil {
+		return
+	}
+	e := p.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.points[key] = obj
+	e.publish(schema.Event{
+		Type:    schema.EventPointLogin,
+		Network: obj.Network,
+		Key:     obj.UUID,
+		Data:    models.NewPointSchema(obj),
+	})
+}
+
+func (p *pointEvents) Del(key string) {
+	e := p.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	reason, ok := e.reasons[key]
+	if !ok {
+		reason = "closed"
+	}
+	delete(e.reasons, key)
+	obj, ok := e.points[key]
+	if !ok {
+		return
+	}
+	delete(e.points, key)
+	e.publish(schema.Event{
+		Type:    schema.EventPointLogout,
+		Network: obj.Network,
+		Key:     obj.UUID,
+		Reason:  reason,
+		Data:    models.NewPointSchema(obj),
+	})
+}
+
+type neighborEvents struct {
+	e *Events
+}
+
+func (n *neighborEvents) Add(key string, value interface{}) {
+	obj, ok := value.(*models.Neighbor)
+	if !ok || obj == nil {
+		return
+	}
+	e := n.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	ev := schema.Event{
+		Type: schema.EventNeighborAdd,
+		Key:  key,
+		Data: models.NewNeighborSchema(obj),
+	}
+	if obj.Client != nil {
+		if point, ok := obj.Client.Private().(*models.Point); ok && point != nil {
+			ev.Network = point.Network
+		}
+	}
+	if older, ok := e.neighbors[key]; ok {
+		ev.Type = schema.EventNeighborMove
+		ev.Reason = "from " + older.HwAddr + " on " + older.Client
+	}
+	e.neighbors[key] = ev.Data.(schema.Neighbor)
+	e.publish(ev)
+}
+
+func (n *neighborEvents) Del(key string) {
+	e := n.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	obj, ok := e.neighbors[key]
+	if !ok {
+		return
+	}
+	delete(e.neighbors, key)
+	e.publish(schema.Event{
+		Type:   schema.EventNeighborExpire,
+		Key:    key,
+		Reason: "timeout",
+		Data:   obj,
+	})
+}
+
+type linkEvents struct {
+	e *Events
+}
+
+func (l *linkEvents) Add(key string, value interface{}) {
+	obj, ok := value.(*models.Point)
+	if !ok || obj == nil || obj.Client == nil {
+		return
+	}
+	e := l.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	link := models.NewLinkSchema(obj)
+	e.links[key] = link
+	e.publish(schema.Event{
+		Type:    schema.EventLinkUp,
+		Network: obj.Network,
+		Key:     key,
+		Data:    link,
+	})
+}
+
+func (l *linkEvents) Del(key string) {
+	e := l.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	link, ok := e.links[key]
+	if !ok {
+		return
+	}
+	delete(e.links, key)
+	e.publish(schema.Event{
+		Type:    schema.EventLinkDown,
+		Network: link.Network,
+		Key:     key,
+		Data:    link,
+	})
+}
+
+type leaseEvents struct {
+	e *Events
+}
+
+func (l *leaseEvents) Add(key string, value interface{}) {
+	e := l.e
+	if n, ok := value.(*models.Network); ok && n != nil {
+		e.Publish(schema.Event{
+			Type:    schema.EventLeaseExhausted,
+			Network: n.Name,
+			Key:     key,
+			Reason:  "no free address in " + n.IpStart + "-" + n.IpEnd,
+		})
+		return
+	}
+	obj, ok := value.(*schema.Lease)
+	if !ok || obj == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.leases[key] = *obj
+	e.publish(schema.Event{
+		Type:    schema.EventLeaseAssign,
+		Network: obj.Network,
+		Key:     key,
+		Data:    *obj,
+	})
+}
+
+func (l *leaseEvents) Del(key string) {
+	e := l.e
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	lease, ok := e.leases[key]
+	if !ok {
+		return
+	}
+	delete(e.leases, key)
+	e.publish(schema.Event{
+		Type:    schema.EventLeaseFree,
+		Network: lease.Network,
+		Key:     key,
+		Data:    lease,
+	})
+}
@@ -0,0 +1,225 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flowMark is counters of a flow already exported.
+type flowMark struct {
+	packets      uint64
+	bytes        uint64
+	replyPackets uint64
+	replyBytes   uint64
+	since        time.Time // start of records not exported.
+	exported     time.Time
+}
+
+// FlowExporter exports flows in online to collectors, a flow is exported
+// if idle out of inactive timeout, closed, or lasted out of active timeout.
+type FlowExporter struct {
+	lock     sync.Mutex
+	cfg      config.FlowExport
+	encoder  libol.FlowEncoder
+	active   time.Duration
+	inactive time.Duration
+	conns    []net.Conn
+	marks    map[string]*flowMark
+	ingress  map[string]uint32 // point -> index of interface.
+	ticker   *time.Ticker
+	done     chan bool
+	started  bool
+}
+
+func NewFlowExporter(c config.FlowExport) *FlowExporter {
+	e := &FlowExporter{
+		cfg:      c,
+		active:   60 * time.Second,
+		inactive: 15 * time.Second,
+		marks:    make(map[string]*flowMark, 1024),
+		ingress:  make(map[string]uint32, 1024),
+		done:     make(chan bool, 1),
+	}
+	if c.Active > 0 {
+		e.active = time.Duration(c.Active) * time.Second
+	}
+	if c.Inactive > 0 {
+		e.inactive = time.Duration(c.Inactive) * time.Second
+	}
+	if c.Protocol == "netflow9" {
+		e.encoder = libol.NewNetFlow9(c.Domain, time.Now())
+	} else {
+		e.encoder = libol.NewIpFix(c.Domain)
+	}
+	return e
+}
+
+// Ingress returns the index of interface for the point.
+func (e *FlowExporter) Ingress(point string) uint32 {
+	if point == "" {
+		return 0
+	}
+	if index, ok := e.ingress[point]; ok {
+		return index
+	}
+	index := uint32(len(e.ingress) + 1)
+	e.ingress[point] = index
+	libol.Info("FlowExporter.Ingress %s as %d", point, index)
+	return index
+}
+
+// records returns records of the line not exported, and marks them.
+func (e *FlowExporter) records(line *models.Line, m *flowMark, end time.Time, reason uint8) []libol.FlowRecord {
+	records := make([]libol.FlowRecord, 0, 2)
+	packets := atomic.LoadUint64(&line.Packets)
+	bytes := atomic.LoadUint64(&line.Bytes)
+	if packets > m.packets {
+		records = append(records, libol.FlowRecord{
+			Source:    line.IpSource,
+			Dest:      line.IpDest,
+			Proto:     line.IpProtocol,
+			SrcPort:   line.PortSource,
+			DstPort:   line.PortDest,
+			Bytes:     bytes - m.bytes,
+			Packets:   packets - m.packets,
+			Start:     m.since,
+			End:       end,
+			Ingress:   e.Ingress(line.Point),
+			EndReason: reason,
+		})
+		m.packets, m.bytes = packets, bytes
+	}
+	replyPackets := atomic.LoadUint64(&line.ReplyPackets)
+	replyBytes := atomic.LoadUint64(&line.ReplyBytes)
+	if replyPackets > m.replyPackets {
+		records = append(records, libol.FlowRecord{
+			Source:    line.IpDest,
+			Dest:      line.IpSource,
+			Proto:     line.IpProtocol,
+			SrcPort:   line.PortDest,
+			DstPort:   line.PortSource,
+			Bytes:     replyBytes - m.replyBytes,
+			Packets:   replyPackets - m.replyPackets,
+			Start:     m.since,
+			End:       end,
+			EndReason: reason,
+		})
+		m.replyPackets, m.replyBytes = replyPackets, replyBytes
+	}
+	if len(records) > 0 {
+		m.since = end
+	}
+	return records
+}
+
+func (e *FlowExporter) collect(now time.Time) []libol.FlowRecord {
+	records := make([]libol.FlowRecord, 0, 64)
+	seen := make(map[string]bool, len(e.marks))
+	for line := range storage.Online.List() {
+		if line == nil {
+			break
+		}
+		if line.Proxy != "" {
+			continue
+		}
+		key := line.String()
+		seen[key] = true
+		m, ok := e.marks[key]
+		if !ok {
+			m = &flowMark{since: time.Unix(line.NewTime, 0), exported: now}
+			e.marks[key] = m
+		}
+		hit := time.Unix(atomic.LoadInt64(&line.HitTime), 0)
+		var reason uint8
+		if line.GetState() == models.TcpClosed {
+			reason = libol.FlowEndOfFlow
+		} else if now.Sub(hit) >= e.inactive {
+			reason = libol.FlowEndIdle
+		} else if now.Sub(m.exported) >= e.active {
+			reason = libol.FlowEndActive
+		} else {
+			continue
+		}
+		records = append(records, e.records(line, m, hit, reason)...)
+		m.exported = now
+	}
+	for key := range e.marks {
+		if !seen[key] {
+			delete(e.marks, key)
+		}
+	}
+	return records
+}
+
+func (e *FlowExporter) send(records []libol.FlowRecord, now time.Time) {
+	max := e.encoder.MaxRecords()
+	for len(records) > 0 {
+		size := len(records)
+		if size > max {
+			size = max
+		}
+		data := e.encoder.Encode(records[:size], now)
+		for _, conn := range e.conns {
+			if _, err := conn.Write(data); err != nil {
+				libol.Debug("FlowExporter.send %s", err)
+			}
+		}
+		records = records[size:]
+	}
+}
+
+func (e *FlowExporter) Start() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.started {
+		return
+	}
+	for _, addr := range e.cfg.Collectors {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			libol.Error("FlowExporter.Start %s", err)
+			continue
+		}
+		e.conns = append(e.conns, conn)
+	}
+	libol.Info("FlowExporter.Start %d collectors by %s", len(e.conns), e.cfg.Protocol)
+	e.started = true
+	e.ticker = time.NewTicker(time.Second)
+	libol.Go(e.Loop)
+}
+
+func (e *FlowExporter) Loop() {
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-e.ticker.C:
+			e.lock.Lock()
+			e.send(e.collect(now), now)
+			e.lock.Unlock()
+		}
+	}
+}
+
+func (e *FlowExporter) Stop() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.started {
+		return
+	}
+	e.started = false
+	e.ticker.Stop()
+	e.done <- true
+	for _, conn := range e.conns {
+		_ = conn.Close()
+	}
+	e.conns = nil
+}
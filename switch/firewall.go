@@ -1,28 +1,274 @@
 package _switch
 
-import "github.com/danieldin95/openlan-go/libol"
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/schema"
+)
 
+// FireWall applies rules into chains owned by the switch, which are
+// flushed on start, so nothing is duplicated or leaked after restart.
+// Rules from user are applied before rules generated by the switch.
 type FireWall struct {
-	lock  libol.Locker
-	rules []libol.FilterRule
+	lock    libol.Locker
+	backend string
+	rules   []libol.FilterRule
+	system  []libol.FilterRule
+	forward []libol.FilterRule
+	chains  map[string]libol.FilterRule // table/chain -> builtin.
+	nft     *libol.NfTable
+	started bool
+	OnError func(err error) // called if failed to apply.
+}
+
+func NewFireWall(backend string) FireWall {
+	return FireWall{
+		backend: backend,
+		rules:   make([]libol.FilterRule, 0, 32),
+		system:  make([]libol.FilterRule, 0, 32),
+		chains:  make(map[string]libol.FilterRule, 8),
+		nft:     libol.NewNfTable("openlan"),
+	}
+}
+
+func ownChain(chain string) string {
+	return "OPENLAN-" + chain
+}
+
+func (f *FireWall) all() []libol.FilterRule {
+	rules := make([]libol.FilterRule, 0, len(f.rules)+len(f.system)+len(f.forward))
+	rules = append(rules, f.rules...)
+	rules = append(rules, f.system...)
+	return append(rules, f.forward...)
+}
+
+// isUser returns true if the rule is from user.
+func (f *FireWall) isUser(rule libol.FilterRule) bool {
+	for _, r := range f.rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FireWall) applyNft() error {
+	skipped, err := f.nft.Apply(f.all())
+	if err != nil {
+		libol.Error("FireWall.applyNft %s", err)
+		f.onError(err)
+		return err
+	}
+	var failed error
+	for _, rule := range skipped {
+		err := libol.NewErr("%s/%s %s not support by nftables", rule.Table, rule.Chain, rule.Jump)
+		libol.Error("FireWall.applyNft skipped %v", rule)
+		f.onError(err)
+		if failed == nil && f.isUser(rule) {
+			failed = err
+		}
+	}
+	return failed
+}
+
+func (f *FireWall) applyIpt() error {
+	rules := f.all()
+	for _, rule := range rules {
+		key := rule.Table + "/" + rule.Chain
+		if _, ok := f.chains[key]; ok {
+			continue
+		}
+		f.chains[key] = rule
+	}
+	for _, rule := range f.chains {
+		if err := libol.IPTablesNewChain(rule.Table, ownChain(rule.Chain), rule.Chain); err != nil {
+			libol.Warn("FireWall.applyIpt %s", err)
+		}
+	}
+	var failed error
+	for _, rule := range rules {
+		user := f.isUser(rule)
+		rule.Chain = ownChain(rule.Chain)
+		if ret, err := libol.IPTables(rule, "-A"); err != nil {
+			libol.Warn("FireWall.applyIpt %s", ret)
+			err := libol.NewErr("%s: %s", rule.Chain, ret)
+			f.onError(err)
+			if failed == nil && user {
+				failed = err
+			}
+		}
+	}
+	return failed
+}
+
+func (f *FireWall) onError(err error) {
+	if f.OnError != nil {
+		f.OnError(err)
+	}
+}
+
+// apply applies all rules, and returns error if failed to apply rules from
+// user, where failures of others are only reported.
+func (f *FireWall) apply() error {
+	if !f.started {
+		return nil
+	}
+	if f.backend == "nftables" {
+		return f.applyNft()
+	}
+	return f.applyIpt()
+}
+
+// update replaces rules from user and applies them, and restores the older
+// rules if failed.
+func (f *FireWall) update(rules []libol.FilterRule) error {
+	older := f.rules
+	f.rules = rules
+	if err := f.apply(); err != nil {
+		f.rules = older
+		_ = f.apply()
+		return err
+	}
+	return nil
 }
 
 func (f *FireWall) Start() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
-	for _, rule := range f.rules {
-		if ret, err := libol.IPTables(rule, "-I"); err != nil {
-			libol.Warn("FireWall.Start %s", ret)
-		}
+	libol.Info("FireWall.Start %d rules by %s", len(f.rules)+len(f.system), f.backend)
+	f.started = true
+	if err := f.apply(); err != nil {
+		libol.Error("FireWall.Start %s", err)
 	}
 }
 
 func (f *FireWall) Stop() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
-	for _, rule := range f.rules {
-		if ret, err := libol.IPTables(rule, "-D"); err != nil {
-			libol.Warn("FireWall.Start %s", ret)
+	f.started = false
+	if f.backend == "nftables" {
+		if err := f.nft.Clear(); err != nil {
+			libol.Warn("FireWall.Stop %s", err)
+		}
+		return
+	}
+	for key, rule := range f.chains {
+		if err := libol.IPTablesDelChain(rule.Table, ownChain(rule.Chain), rule.Chain); err != nil {
+			libol.Warn("FireWall.Stop %s", err)
 		}
+		delete(f.chains, key)
+	}
+}
+
+func (f *FireWall) AddSystem(rule libol.FilterRule) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.system = append(f.system, rule)
+}
+
+// Load appends the rule from configuration as before, and warns if it's
+// not supported by the API.
+func (f *FireWall) Load(rule libol.FilterRule) {
+	if err := rule.Validate(); err != nil {
+		libol.Warn("FireWall.Load %v: %s", rule, err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.rules = append(f.rules, rule)
+}
+
+// SetForward replaces rules for port forwarding, and applies them.
+func (f *FireWall) SetForward(rules []libol.FilterRule) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.forward = rules
+	if err := f.apply(); err != nil {
+		libol.Error("FireWall.SetForward %s", err)
+	}
+}
+
+func (f *FireWall) List() []schema.FireWall {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	all := f.all()
+	rules := make([]schema.FireWall, 0, len(all))
+	for i, rule := range all {
+		rules = append(rules, schema.FireWall{
+			Index:    i,
+			Table:    rule.Table,
+			Chain:    rule.Chain,
+			Input:    rule.Input,
+			Source:   rule.Source,
+			ToSource: rule.ToSource,
+			Dest:     rule.Dest,
+			ToDest:   rule.ToDest,
+			Output:   rule.Output,
+			Comment:  rule.Comment,
+			Jump:     rule.Jump,
+			Proto:    rule.Proto,
+			DstPort:  rule.DstPort,
+			System:   i >= len(f.rules),
+		})
+	}
+	return rules
+}
+
+// Rules returns rules from user.
+func (f *FireWall) Rules() []libol.FilterRule {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]libol.FilterRule{}, f.rules...)
+}
+
+// Add inserts the rule at index, and appends it to the last rule from
+// user if index is out of range.
+func (f *FireWall) Add(rule libol.FilterRule, index int) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if index < 0 || index > len(f.rules) {
+		index = len(f.rules)
+	}
+	rules := make([]libol.FilterRule, 0, len(f.rules)+1)
+	rules = append(rules, f.rules[:index]...)
+	rules = append(rules, rule)
+	rules = append(rules, f.rules[index:]...)
+	return f.update(rules)
+}
+
+func (f *FireWall) Del(index int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if index >= len(f.rules) && index < len(f.rules)+len(f.system)+len(f.forward) {
+		return libol.NewErr("rule %d is system-owned", index)
+	}
+	if index < 0 || index >= len(f.rules) {
+		return libol.NewErr("rule %d not found", index)
+	}
+	rules := make([]libol.FilterRule, 0, len(f.rules))
+	rules = append(rules, f.rules[:index]...)
+	rules = append(rules, f.rules[index+1:]...)
+	return f.update(rules)
+}
+
+// Move changes the position of the rule from user.
+func (f *FireWall) Move(from, to int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if from < 0 || from >= len(f.rules) {
+		return libol.NewErr("rule %d not found or system-owned", from)
+	}
+	if to < 0 || to >= len(f.rules) {
+		return libol.NewErr("invalid position %d", to)
 	}
+	rules := make([]libol.FilterRule, 0, len(f.rules))
+	rules = append(rules, f.rules[:from]...)
+	rules = append(rules, f.rules[from+1:]...)
+	rules = append(rules[:to], append([]libol.FilterRule{f.rules[from]}, rules[to:]...)...)
+	return f.update(rules)
 }
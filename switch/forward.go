@@ -0,0 +1,406 @@
+package _switch
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolvePoint returns the address leased to the point by uuid or alias.
+func resolvePoint(network, point string) string {
+	if p := storage.Point.GetByUUID(point); p != nil && p.Network == network {
+		return storage.Network.GetAddr(p.UUID)
+	}
+	_, addr := findPoint(strings.ToLower(point), strings.ToLower(network))
+	return addr
+}
+
+// forwarder is a port published by DNAT rules if the network is in the
+// kernel, otherwise by a userspace proxy.
+type forwarder struct {
+	lock     sync.Mutex
+	cfg      config.Forward
+	mode     string
+	address  string
+	listener net.Listener
+	conn     *net.UDPConn
+	sessions map[string]*net.UDPConn // client -> point.
+}
+
+func (f *forwarder) Key() string {
+	return fmt.Sprintf("%s:%d", f.cfg.Protocol, f.cfg.Port)
+}
+
+func (f *forwarder) Target() string {
+	addr := resolvePoint(f.cfg.Network, f.cfg.Point)
+	if addr == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", addr, f.cfg.PointPort)
+}
+
+func (f *forwarder) Rules() []libol.FilterRule {
+	if f.address == "" {
+		return nil
+	}
+	return []libol.FilterRule{
+		{
+			Table:   "nat",
+			Chain:   "PREROUTING",
+			Proto:   f.cfg.Protocol,
+			DstPort: f.cfg.Port,
+			Jump:    "DNAT",
+			ToDest:  fmt.Sprintf("%s:%d", f.address, f.cfg.PointPort),
+		},
+		{
+			Table:   "filter",
+			Chain:   "FORWARD",
+			Dest:    f.address,
+			Proto:   f.cfg.Protocol,
+			DstPort: f.cfg.PointPort,
+			Jump:    "ACCEPT",
+		},
+		{
+			Table:   "nat",
+			Chain:   "POSTROUTING",
+			Dest:    f.address,
+			Proto:   f.cfg.Protocol,
+			DstPort: f.cfg.PointPort,
+			Jump:    "MASQUERADE",
+		},
+	}
+}
+
+func (f *forwarder) Start() {
+	if f.mode != "proxy" {
+		return
+	}
+	addr := fmt.Sprintf(":%d", f.cfg.Port)
+	if f.cfg.Protocol == "tcp" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			libol.Error("forwarder.Start %s: %s", f.Key(), err)
+			return
+		}
+		f.lock.Lock()
+		f.listener = listener
+		f.lock.Unlock()
+		libol.Go(func() { f.acceptTcp(listener) })
+	} else {
+		udpAddr, _ := net.ResolveUDPAddr("udp", addr)
+		conn, err := net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			libol.Error("forwarder.Start %s: %s", f.Key(), err)
+			return
+		}
+		f.lock.Lock()
+		f.conn = conn
+		f.sessions = make(map[string]*net.UDPConn, 32)
+		f.lock.Unlock()
+		libol.Go(func() { f.readUdp(conn) })
+	}
+	libol.Info("forwarder.Start %s to %s:%d on %s", f.Key(), f.cfg.Point, f.cfg.PointPort, f.cfg.Network)
+}
+
+func (f *forwarder) Stop() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.listener != nil {
+		_ = f.listener.Close()
+		f.listener = nil
+	}
+	if f.conn != nil {
+		_ = f.conn.Close()
+		f.conn = nil
+	}
+	for client, up := range f.sessions {
+		_ = up.Close()
+		delete(f.sessions, client)
+	}
+}
+
+func (f *forwarder) acceptTcp(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			libol.Debug("forwarder.acceptTcp %s: %s", f.Key(), err)
+			return
+		}
+		libol.Go(func() { f.proxyTcp(conn) })
+	}
+}
+
+func (f *forwarder) proxyTcp(conn net.Conn) {
+	defer conn.Close()
+	target := f.Target()
+	if target == "" {
+		libol.Warn("forwarder.proxyTcp %s: %s offline", f.Key(), f.cfg.Point)
+		return
+	}
+	up, err := net.DialTimeout("tcp", target, 5*time.Second)
+	if err != nil {
+		libol.Warn("forwarder.proxyTcp %s: %s", f.Key(), err)
+		return
+	}
+	defer up.Close()
+	libol.Debug("forwarder.proxyTcp %s -> %s", conn.RemoteAddr(), target)
+	done := make(chan bool, 2)
+	libol.Go(func() {
+		_, _ = io.Copy(up, conn)
+		done <- true
+	})
+	libol.Go(func() {
+		_, _ = io.Copy(conn, up)
+		done <- true
+	})
+	<-done
+}
+
+func (f *forwarder) readUdp(conn *net.UDPConn) {
+	data := make([]byte, 65535)
+	for {
+		n, from, err := conn.ReadFromUDP(data)
+		if err != nil {
+			libol.Debug("forwarder.readUdp %s: %s", f.Key(), err)
+			return
+		}
+		f.lock.Lock()
+		up, ok := f.sessions[from.String()]
+		f.lock.Unlock()
+		if !ok {
+			target := f.Target()
+			if target == "" {
+				continue
+			}
+			addr, err := net.ResolveUDPAddr("udp", target)
+			if err != nil {
+				continue
+			}
+			if up, err = net.DialUDP("udp", nil, addr); err != nil {
+				libol.Warn("forwarder.readUdp %s: %s", f.Key(), err)
+				continue
+			}
+			f.lock.Lock()
+			f.sessions[from.String()] = up
+			f.lock.Unlock()
+			client := from
+			libol.Go(func() { f.replyUdp(conn, up, client) })
+		}
+		_, _ = up.Write(data[:n])
+	}
+}
+
+// replyUdp writes replies from point to the client until idle a minute.
+func (f *forwarder) replyUdp(conn, up *net.UDPConn, client *net.UDPAddr) {
+	defer func() {
+		f.lock.Lock()
+		delete(f.sessions, client.String())
+		f.lock.Unlock()
+		_ = up.Close()
+	}()
+	data := make([]byte, 65535)
+	for {
+		_ = up.SetReadDeadline(time.Now().Add(time.Minute))
+		n, err := up.Read(data)
+		if err != nil {
+			return
+		}
+		if _, err := conn.WriteToUDP(data[:n], client); err != nil {
+			return
+		}
+	}
+}
+
+// Forwarder publishes ports of points, and updates DNAT rules when the
+// address leased to a point changed.
+type Forwarder struct {
+	lock     sync.Mutex
+	entries  map[string]*forwarder // protocol:port -> forwarder.
+	modes    map[string]string     // network -> mode.
+	firewall *FireWall
+	ticker   *time.Ticker
+	done     chan bool
+	started  bool
+}
+
+func NewForwarder(c *config.Switch, firewall *FireWall) *Forwarder {
+	f := &Forwarder{
+		entries:  make(map[string]*forwarder, 32),
+		modes:    make(map[string]string, 32),
+		firewall: firewall,
+		done:     make(chan bool, 1),
+	}
+	for _, nCfg := range c.Network {
+		if nCfg.IsRouted() || nCfg.Bridge.Provider == "linux" {
+			f.modes[nCfg.Name] = "dnat"
+		} else {
+			f.modes[nCfg.Name] = "proxy"
+		}
+	}
+	return f
+}
+
+func (f *Forwarder) Add(c config.Forward) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	c.Protocol = strings.ToLower(c.Protocol)
+	if c.Protocol != "tcp" && c.Protocol != "udp" {
+		return libol.NewErr("invalid protocol %s", c.Protocol)
+	}
+	if c.Port <= 0 || c.Port > 65535 || c.PointPort <= 0 || c.PointPort > 65535 {
+		return libol.NewErr("invalid port %d or %d", c.Port, c.PointPort)
+	}
+	if c.Point == "" {
+		return libol.NewErr("point is required")
+	}
+	mode, ok := f.modes[c.Network]
+	if !ok {
+		return libol.NewErr("network %s not found", c.Network)
+	}
+	fwd := &forwarder{cfg: c, mode: mode}
+	if _, ok := f.entries[fwd.Key()]; ok {
+		return libol.NewErr("%s already existed", fwd.Key())
+	}
+	f.entries[fwd.Key()] = fwd
+	if f.started {
+		fwd.Start()
+		f.refresh(true)
+	}
+	return nil
+}
+
+func (f *Forwarder) Del(protocol string, port int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := fmt.Sprintf("%s:%d", strings.ToLower(protocol), port)
+	fwd, ok := f.entries[key]
+	if !ok {
+		return libol.NewErr("%s not found", key)
+	}
+	delete(f.entries, key)
+	fwd.Stop()
+	if f.started {
+		f.refresh(true)
+	}
+	return nil
+}
+
+func (f *Forwarder) sorted() []*forwarder {
+	entries := make([]*forwarder, 0, len(f.entries))
+	for _, fwd := range f.entries {
+		entries = append(entries, fwd)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key() < entries[j].Key()
+	})
+	return entries
+}
+
+func (f *Forwarder) List() []schema.Forward {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	list := make([]schema.Forward, 0, len(f.entries))
+	for _, fwd := range f.sorted() {
+		list = append(list, schema.Forward{
+			Network:   fwd.cfg.Network,
+			Protocol:  fwd.cfg.Protocol,
+			Port:      fwd.cfg.Port,
+			Point:     fwd.cfg.Point,
+			PointPort: fwd.cfg.PointPort,
+			Address:   resolvePoint(fwd.cfg.Network, fwd.cfg.Point),
+			Mode:      fwd.mode,
+		})
+	}
+	return list
+}
+
+// Configs returns all forwards to save.
+func (f *Forwarder) Configs() []config.Forward {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	configs := make([]config.Forward, 0, len(f.entries))
+	for _, fwd := range f.sorted() {
+		configs = append(configs, fwd.cfg)
+	}
+	return configs
+}
+
+// refresh resolves addresses of points, and updates rules if changed.
+func (f *Forwarder) refresh(force bool) {
+	changed := force
+	for _, fwd := range f.entries {
+		if fwd.mode != "dnat" {
+			continue
+		}
+		addr := resolvePoint(fwd.cfg.Network, fwd.cfg.Point)
+		if addr != fwd.address {
+			libol.Info("Forwarder.refresh %s to %s", fwd.Key(), addr)
+			fwd.address = addr
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	rules := make([]libol.FilterRule, 0, 32)
+	for _, fwd := range f.sorted() {
+		rules = append(rules, fwd.Rules()...)
+	}
+	f.firewall.SetForward(rules)
+}
+
+func (f *Forwarder) Start() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.started = true
+	f.ticker = time.NewTicker(5 * time.Second)
+	for _, fwd := range f.entries {
+		fwd.Start()
+	}
+	f.refresh(true)
+	libol.Go(f.Loop)
+}
+
+func (f *Forwarder) Loop() {
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-f.ticker.C:
+			f.lock.Lock()
+			if f.started {
+				f.refresh(false)
+			}
+			f.lock.Unlock()
+		}
+	}
+}
+
+func (f *Forwarder) Stop() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.started {
+		return
+	}
+	f.started = false
+	f.ticker.Stop()
+	f.done <- true
+	for _, fwd := range f.entries {
+		fwd.Stop()
+	}
+}
@@ -2,6 +2,8 @@ package _switch
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
@@ -76,10 +78,49 @@ func (h *Http) PProf(r *mux.Router) {
 	}
 }
 
+// statusWriter saves the status of response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// tokenId returns the prefix of sha256 of the token, and never saves the
+// token itself.
+func tokenId(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// audit records the request if it changes something.
+func (h *Http) audit(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	switch r.Method {
+	case "POST", "PUT", "PATCH", "DELETE":
+	default:
+		next.ServeHTTP(w, r)
+		return
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(sw, r)
+	token, _, _ := r.BasicAuth()
+	h.switcher.AddAudit(schema.Audit{
+		Type:   schema.AuditApi,
+		Actor:  tokenId(token),
+		Source: r.RemoteAddr,
+		Action: r.Method,
+		Target: r.URL.Path,
+		Detail: fmt.Sprintf("%d %s", sw.status, http.StatusText(sw.status)),
+	})
+}
+
 func (h *Http) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if h.IsAuth(w, r) {
-			next.ServeHTTP(w, r)
+			h.audit(w, r, next)
 		} else {
 			w.Header().Set("WWW-Authenticate", "Basic")
 			http.Error(w, "Authorization Required.", http.StatusUnauthorized)
@@ -132,13 +173,22 @@ func (h *Http) LoadRouter() {
 	})
 	api.Link{Switcher: h.switcher}.Router(router)
 	api.User{}.Router(router)
-	api.Neighbor{}.Router(router)
+	api.Neighbor{Switcher: h.switcher}.Router(router)
 	api.Point{}.Router(router)
-	api.Network{}.Router(router)
+	api.Network{Switcher: h.switcher}.Router(router)
 	api.OnLine{}.Router(router)
 	api.Ctrl{Switcher: h.switcher}.Router(router)
 	api.Lease{}.Router(router)
 	api.Server{Switcher: h.switcher}.Router(router)
+	api.FireWall{Switcher: h.switcher}.Router(router)
+	api.Forward{Switcher: h.switcher}.Router(router)
+	api.Usage{Switcher: h.switcher}.Router(router)
+	api.Capture{Switcher: h.switcher}.Router(router)
+	api.Trace{Switcher: h.switcher}.Router(router)
+	api.Log{}.Router(router)
+	api.Event{Switcher: h.switcher}.Router(router)
+	api.Audit{Switcher: h.switcher}.Router(router)
+	api.Metrics{Switcher: h.switcher}.Router(router)
 }
 
 func (h *Http) LoadToken() error {
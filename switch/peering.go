@@ -0,0 +1,57 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+)
+
+// Peering maintains kernel routes to prefixes behind a gateway in the
+// peer network, and drops forwarding between subnets of peered networks
+// not accepted by prefixes.
+type Peering struct {
+	lock   libol.Locker
+	routes []config.PrefixRoute
+	drops  []libol.FilterRule
+}
+
+// addDrop drops forwarding between both subnets in both directions once.
+func (p *Peering) addDrop(source, dest string) {
+	for _, rule := range p.drops {
+		if rule.Source == source && rule.Dest == dest {
+			return
+		}
+	}
+	p.drops = append(p.drops, libol.FilterRule{
+		Table:  "filter",
+		Chain:  "FORWARD",
+		Source: source,
+		Dest:   dest,
+		Jump:   "DROP",
+	}, libol.FilterRule{
+		Table:  "filter",
+		Chain:  "FORWARD",
+		Source: dest,
+		Dest:   source,
+		Jump:   "DROP",
+	})
+}
+
+func (p *Peering) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, rt := range p.routes {
+		if out, err := libol.IpRouteAdd("", rt.Prefix, rt.NextHop); err != nil {
+			libol.Warn("Peering.Start %s via %s: %s", rt.Prefix, rt.NextHop, out)
+		}
+	}
+}
+
+func (p *Peering) Stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, rt := range p.routes {
+		if out, err := libol.IpRouteDel("", rt.Prefix, rt.NextHop); err != nil {
+			libol.Warn("Peering.Stop %s via %s: %s", rt.Prefix, rt.NextHop, out)
+		}
+	}
+}
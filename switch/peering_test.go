@@ -0,0 +1,46 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSwitch_addPeers(t *testing.T) {
+	n1 := &config.Network{
+		Name:   "n1",
+		Bridge: config.Bridge{Address: "192.168.1.1/24"},
+		Peers:  []config.Peer{{Network: "n2", Prefixes: []string{"192.168.2.10/32"}}},
+	}
+	n2 := &config.Network{
+		Name:   "n2",
+		Bridge: config.Bridge{Address: "192.168.2.1/24"},
+		Peers:  []config.Peer{{Network: "n1"}},
+	}
+	v := &Switch{
+		cfg:      config.Switch{Network: []*config.Network{n1, n2}},
+		firewall: NewFireWall("iptables"),
+	}
+	v.addPeers(n1)
+	v.addPeers(n2)
+	v.addDrops()
+
+	rules := v.firewall.system
+	accept := func(src, dst string) libol.FilterRule {
+		return libol.FilterRule{Table: "filter", Chain: "FORWARD", Source: src, Dest: dst, Jump: "ACCEPT"}
+	}
+	drop := func(src, dst string) libol.FilterRule {
+		return libol.FilterRule{Table: "filter", Chain: "FORWARD", Source: src, Dest: dst, Jump: "DROP"}
+	}
+	assert.Equal(t, []libol.FilterRule{
+		accept("192.168.1.1/24", "192.168.2.10/32"),
+		accept("192.168.2.10/32", "192.168.1.1/24"),
+		accept("192.168.2.1/24", "192.168.1.0/24"),
+		accept("192.168.1.0/24", "192.168.2.1/24"),
+		drop("192.168.1.0/24", "192.168.2.0/24"),
+		drop("192.168.2.0/24", "192.168.1.0/24"),
+	}, rules, "drops after accepts of all networks")
+	assert.Equal(t, "192.168.2.10/32", n1.Routes[0].Prefix)
+	assert.Equal(t, "192.168.1.1", n1.Routes[0].NextHop)
+}
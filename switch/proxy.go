@@ -0,0 +1,395 @@
+package _switch
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	socks5Ver      = 0x05
+	socks5AuthVer  = 0x01
+	socks5UserPass = 0x02
+	socks5NoAccept = 0xff
+	socks5Connect  = 0x01
+	socks5Ipv4     = 0x01
+	socks5Domain   = 0x03
+	socks5Ipv6     = 0x04
+
+	socks5Succeeded   = 0x00
+	socks5NotAllowed  = 0x02
+	socks5Unreachable = 0x04
+	socks5NotSupport  = 0x07
+	socks5AddrSupport = 0x08
+)
+
+type proxyAllow struct {
+	prefix *net.IPNet
+	port   int // 0 is any.
+}
+
+func (a proxyAllow) Match(ip net.IP, port int) bool {
+	return a.prefix.Contains(ip) && (a.port == 0 || a.port == port)
+}
+
+// parseAllow parses prefix or prefix:port, such as 192.168.1.0/24:22.
+func parseAllow(value string) (proxyAllow, error) {
+	allow := proxyAllow{}
+	if i := strings.LastIndex(value, ":"); i > 0 {
+		port, err := strconv.Atoi(value[i+1:])
+		if err != nil || port <= 0 || port > 65535 {
+			return allow, libol.NewErr("invalid port in %s", value)
+		}
+		allow.port = port
+		value = value[:i]
+	}
+	if !strings.Contains(value, "/") {
+		value += "/32"
+	}
+	_, prefix, err := net.ParseCIDR(value)
+	if err != nil {
+		return allow, libol.NewErr("invalid prefix %s", value)
+	}
+	allow.prefix = prefix
+	return allow, nil
+}
+
+// ProxyServer accepts socks5 and http connect on one listen address, and
+// authenticates users of the network.
+type ProxyServer struct {
+	lock     sync.Mutex
+	name     string
+	listen   string
+	address  net.IP
+	allowed  []proxyAllow
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+func NewProxyServer(c config.Network) *ProxyServer {
+	p := &ProxyServer{
+		name:    c.Name,
+		listen:  c.Proxy.Listen,
+		address: net.ParseIP(strings.SplitN(c.Bridge.Address, "/", 2)[0]),
+		allowed: make([]proxyAllow, 0, len(c.Proxy.Allowed)),
+		conns:   make(map[net.Conn]bool, 32),
+	}
+	for _, value := range c.Proxy.Allowed {
+		allow, err := parseAllow(value)
+		if err != nil {
+			libol.Error("NewProxyServer %s: %s", c.Name, err)
+			continue
+		}
+		p.allowed = append(p.allowed, allow)
+	}
+	return p
+}
+
+func (p *ProxyServer) Start() {
+	if p.address == nil {
+		libol.Warn("ProxyServer.Start %s: bridge without address", p.name)
+		return
+	}
+	listener, err := net.Listen("tcp", p.listen)
+	if err != nil {
+		libol.Error("ProxyServer.Start %s: %s", p.name, err)
+		return
+	}
+	p.lock.Lock()
+	p.listener = listener
+	p.lock.Unlock()
+	libol.Info("ProxyServer.Start %s on %s", p.name, p.listen)
+	libol.Go(p.Loop)
+}
+
+func (p *ProxyServer) Stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.listener != nil {
+		_ = p.listener.Close()
+		p.listener = nil
+	}
+	for conn := range p.conns {
+		_ = conn.Close()
+		delete(p.conns, conn)
+	}
+}
+
+func (p *ProxyServer) Loop() {
+	for {
+		p.lock.Lock()
+		listener := p.listener
+		p.lock.Unlock()
+		if listener == nil {
+			break
+		}
+		conn, err := listener.Accept()
+		if err != nil {
+			libol.Debug("ProxyServer.Loop %s: %s", p.name, err)
+			break
+		}
+		p.lock.Lock()
+		p.conns[conn] = true
+		p.lock.Unlock()
+		libol.Go(func() {
+			p.handle(conn)
+			p.lock.Lock()
+			delete(p.conns, conn)
+			p.lock.Unlock()
+			_ = conn.Close()
+		})
+	}
+	libol.Info("ProxyServer.Loop %s exit", p.name)
+}
+
+// auth returns the name of user if the password is right.
+func (p *ProxyServer) auth(name, password string) (string, bool) {
+	if !strings.Contains(name, "@") {
+		name = name + "@" + p.name
+	}
+	if !strings.HasSuffix(name, "@"+p.name) {
+		return name, false
+	}
+	user := storage.User.Get(name)
+	return name, user != nil && user.Password == password
+}
+
+// resolve returns the address of host if it's allowed.
+func (p *ProxyServer) resolve(host string, port int) (net.IP, bool) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil || len(ips) == 0 {
+			return nil, false
+		}
+		ip = ips[0]
+	}
+	for _, allow := range p.allowed {
+		if allow.Match(ip, port) {
+			return ip, true
+		}
+	}
+	return ip, false
+}
+
+func (p *ProxyServer) dial(ip net.IP, port int) (net.Conn, error) {
+	dialer := net.Dialer{
+		Timeout:   5 * time.Second,
+		LocalAddr: &net.TCPAddr{IP: p.address},
+	}
+	return dialer.Dial("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+}
+
+func (p *ProxyServer) handle(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] == socks5Ver {
+		p.socks5(conn, reader)
+	} else {
+		p.connect(conn, reader)
+	}
+}
+
+// pipe copies between conn and up, and shows the session in online.
+func (p *ProxyServer) pipe(conn net.Conn, reader io.Reader, up net.Conn, user, proxy string) {
+	_ = conn.SetDeadline(time.Time{})
+	line := models.NewLine(libol.EthIp4)
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		line.IpSource = addr.IP
+		line.PortSource = uint16(addr.Port)
+	}
+	if addr, ok := up.RemoteAddr().(*net.TCPAddr); ok {
+		line.IpDest = addr.IP
+		line.PortDest = uint16(addr.Port)
+	}
+	line.IpProtocol = libol.IpTcp
+	line.User = user
+	line.Proxy = proxy
+	storage.Online.Add(line)
+	defer storage.Online.Del(line.String())
+
+	libol.Info("ProxyServer.pipe %s by %s to %s", user, proxy, up.RemoteAddr())
+	done := make(chan bool, 2)
+	libol.Go(func() {
+		_, _ = io.Copy(up, reader)
+		done <- true
+	})
+	libol.Go(func() {
+		_, _ = io.Copy(conn, up)
+		done <- true
+	})
+	<-done
+	_ = up.Close()
+}
+
+func (p *ProxyServer) socks5Reply(conn net.Conn, rep byte) {
+	_, _ = conn.Write([]byte{socks5Ver, rep, 0x00, socks5Ipv4, 0, 0, 0, 0, 0, 0})
+}
+
+func (p *ProxyServer) socks5(conn net.Conn, reader *bufio.Reader) {
+	// methods, only username and password.
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(reader, methods); err != nil {
+		return
+	}
+	found := false
+	for _, m := range methods {
+		found = found || m == socks5UserPass
+	}
+	if !found {
+		_, _ = conn.Write([]byte{socks5Ver, socks5NoAccept})
+		return
+	}
+	_, _ = conn.Write([]byte{socks5Ver, socks5UserPass})
+
+	// username and password by RFC1929.
+	if _, err := io.ReadFull(reader, head); err != nil || head[0] != socks5AuthVer {
+		return
+	}
+	name := make([]byte, head[1])
+	if _, err := io.ReadFull(reader, name); err != nil {
+		return
+	}
+	size, err := reader.ReadByte()
+	if err != nil {
+		return
+	}
+	password := make([]byte, size)
+	if _, err := io.ReadFull(reader, password); err != nil {
+		return
+	}
+	user, ok := p.auth(string(name), string(password))
+	if !ok {
+		libol.Warn("ProxyServer.socks5 %s: %s auth failed", p.name, user)
+		_, _ = conn.Write([]byte{socks5AuthVer, 0x01})
+		return
+	}
+	_, _ = conn.Write([]byte{socks5AuthVer, 0x00})
+
+	// request.
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(reader, req); err != nil {
+		return
+	}
+	if req[1] != socks5Connect {
+		p.socks5Reply(conn, socks5NotSupport)
+		return
+	}
+	var host string
+	switch req[3] {
+	case socks5Ipv4, socks5Ipv6:
+		addr := make([]byte, 4)
+		if req[3] == socks5Ipv6 {
+			addr = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(reader, addr); err != nil {
+			return
+		}
+		host = net.IP(addr).String()
+	case socks5Domain:
+		size, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		domain := make([]byte, size)
+		if _, err := io.ReadFull(reader, domain); err != nil {
+			return
+		}
+		host = string(domain)
+	default:
+		p.socks5Reply(conn, socks5AddrSupport)
+		return
+	}
+	data := make([]byte, 2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return
+	}
+	port := int(binary.BigEndian.Uint16(data))
+	ip, ok := p.resolve(host, port)
+	if !ok {
+		libol.Warn("ProxyServer.socks5 %s: %s to %s:%d not allowed", p.name, user, host, port)
+		p.socks5Reply(conn, socks5NotAllowed)
+		return
+	}
+	up, err := p.dial(ip, port)
+	if err != nil {
+		libol.Warn("ProxyServer.socks5 %s: %s", p.name, err)
+		p.socks5Reply(conn, socks5Unreachable)
+		return
+	}
+	reply := []byte{socks5Ver, socks5Succeeded, 0x00, socks5Ipv4, 0, 0, 0, 0, 0, 0}
+	if addr, ok := up.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() != nil {
+		copy(reply[4:8], addr.IP.To4())
+		binary.BigEndian.PutUint16(reply[8:10], uint16(addr.Port))
+	}
+	_, _ = conn.Write(reply)
+	p.pipe(conn, reader, up, user, "socks5")
+}
+
+func (p *ProxyServer) connect(conn net.Conn, reader *bufio.Reader) {
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\n\r\n"))
+		return
+	}
+	user := ""
+	ok := false
+	auth := req.Header.Get("Proxy-Authorization")
+	if strings.HasPrefix(auth, "Basic ") {
+		if data, err := base64.StdEncoding.DecodeString(auth[6:]); err == nil {
+			values := strings.SplitN(string(data), ":", 2)
+			if len(values) == 2 {
+				user, ok = p.auth(values[0], values[1])
+			}
+		}
+	}
+	if !ok {
+		libol.Warn("ProxyServer.connect %s: %s auth failed", p.name, user)
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n" +
+			"Proxy-Authenticate: Basic realm=\"openlan\"\r\n\r\n"))
+		return
+	}
+	host, value, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+		return
+	}
+	port, _ := strconv.Atoi(value)
+	ip, ok := p.resolve(host, port)
+	if !ok {
+		libol.Warn("ProxyServer.connect %s: %s to %s not allowed", p.name, user, req.Host)
+		_, _ = conn.Write([]byte("HTTP/1.1 403 Forbidden\r\n\r\n"))
+		return
+	}
+	up, err := p.dial(ip, port)
+	if err != nil {
+		libol.Warn("ProxyServer.connect %s: %s", p.name, err)
+		_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	p.pipe(conn, reader, up, user, "http")
+}
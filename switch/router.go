@@ -0,0 +1,255 @@
+package _switch
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"sync"
+)
+
+type route struct {
+	client libol.SocketClient
+	uuid   string
+}
+
+// Router acts as the gateway of a routed network. It forwards ip packets
+// between points by /32 routes from the lease table, and sends others to
+// a kernel tun device, so arp is never flooded to points. Points still
+// exchange ethernet frames over their tap devices, and the router answers
+// their arp requests by proxy, which is not framing of layer 3 like tun.
+type Router struct {
+	lock    sync.RWMutex
+	name    string
+	address string
+	hwAddr  []byte
+	device  network.Taper
+	routes  map[string]*route // ip -> point.
+	hosts   map[string][]byte // client -> ethernet address.
+}
+
+func NewRouter(c config.Network) *Router {
+	return &Router{
+		name:    c.Name,
+		address: c.Bridge.Address,
+		hwAddr:  libol.GenEthAddr(6),
+		routes:  make(map[string]*route, 1024),
+		hosts:   make(map[string][]byte, 1024),
+	}
+}
+
+func (r *Router) Open() {
+	libol.Info("Router.Open %s", r.name)
+	dev, err := network.NewKernelTap(r.name, network.TapConfig{Type: network.TUN})
+	if err != nil {
+		libol.Error("Router.Open %s", err)
+		return
+	}
+	if out, err := libol.IpLinkUp(dev.Name()); err != nil {
+		libol.Error("Router.Open.IpLink %s:%s", err, out)
+	}
+	if r.address != "" {
+		if out, err := libol.IpAddrAdd(dev.Name(), r.address); err != nil {
+			libol.Error("Router.Open.IpAddr %s:%s", err, out)
+		}
+	}
+	r.lock.Lock()
+	r.device = dev
+	r.lock.Unlock()
+	libol.Info("Router.Open %s on %s", r.name, dev.Name())
+	libol.Go(r.Read)
+}
+
+func (r *Router) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.device == nil {
+		return
+	}
+	if r.address != "" {
+		if out, err := libol.IpAddrDel(r.device.Name(), r.address); err != nil {
+			libol.Error("Router.Close.IpAddr %s:%s", err, out)
+		}
+	}
+	_ = r.device.Close()
+	r.device = nil
+}
+
+// findRoute returns the point leased with ip in this network.
+func (r *Router) findRoute(ip net.IP) *route {
+	addr := ip.String()
+
+	r.lock.RLock()
+	rt, ok := r.routes[addr]
+	r.lock.RUnlock()
+	if ok && storage.Network.GetAddr(rt.uuid) == addr {
+		return rt
+	}
+
+	uuid := storage.Network.AddrUUID.Get(addr)
+	if uuid == "" {
+		return nil
+	}
+	p := storage.Point.GetByUUID(uuid)
+	if p == nil || p.Network != r.name {
+		return nil
+	}
+	rt = &route{client: p.Client, uuid: uuid}
+	r.lock.Lock()
+	r.routes[addr] = rt
+	r.lock.Unlock()
+	libol.Info("Router.findRoute: %s/32 via %s", addr, p.Client)
+	return rt
+}
+
+func (r *Router) newEth(t uint16, dst []byte) *libol.Ether {
+	eth := libol.NewEther(t)
+	eth.Dst = dst
+	eth.Src = r.hwAddr
+	return eth
+}
+
+// toPoint writes the ip packet to the point by the route.
+func (r *Router) toPoint(rt *route, data []byte) {
+	r.lock.RLock()
+	dst, ok := r.hosts[rt.client.Addr()]
+	r.lock.RUnlock()
+	if !ok {
+		dst = libol.BROADED
+	}
+	eth := r.newEth(libol.EthIp4, dst)
+	buffer := make([]byte, 0, eth.Len+len(data))
+	buffer = append(buffer, eth.Encode()...)
+	buffer = append(buffer, data...)
+	if err := rt.client.WriteMsg(buffer); err != nil {
+		libol.Debug("Router.toPoint: %s %s", rt.client, err)
+	}
+}
+
+// proxyArp replies the arp request by the ethernet address of this router,
+// but not probes of duplicate address from 0.0.0.0 or requests for the
+// address leased to the requester itself.
+func (r *Router) proxyArp(client libol.SocketClient, arp *libol.Arp) {
+	if arp.OpCode != libol.ArpRequest || bytes.Equal(arp.SIpAddr, arp.TIpAddr) {
+		return
+	}
+	if net.IP(arp.SIpAddr).Equal(net.IPv4zero) {
+		return
+	}
+	if rt := r.findRoute(net.IP(arp.TIpAddr)); rt != nil && rt.client == client {
+		return
+	}
+	eth := r.newEth(libol.EthArp, arp.SHwAddr)
+	reply := libol.NewArp()
+	reply.OpCode = libol.ArpReply
+	reply.SHwAddr = r.hwAddr
+	reply.SIpAddr = arp.TIpAddr
+	reply.THwAddr = arp.SHwAddr
+	reply.TIpAddr = arp.SIpAddr
+
+	buffer := make([]byte, 0, 64)
+	buffer = append(buffer, eth.Encode()...)
+	buffer = append(buffer, reply.Encode()...)
+	if err := client.WriteMsg(buffer); err != nil {
+		libol.Error("Router.proxyArp: %s", err)
+	}
+}
+
+// Input processes the ethernet frame received from the point.
+func (r *Router) Input(client libol.SocketClient, data []byte) error {
+	eth, err := libol.NewEtherFromFrame(data)
+	if err != nil {
+		return err
+	}
+	if network.IsMulticast(eth.Src) {
+		return nil
+	}
+	r.lock.Lock()
+	if hw, ok := r.hosts[client.Addr()]; !ok || !bytes.Equal(hw, eth.Src) {
+		r.hosts[client.Addr()] = append([]byte{}, eth.Src...)
+	}
+	r.lock.Unlock()
+
+	payload := data[eth.Len:]
+	if eth.IsArp() {
+		arp, err := libol.NewArpFromFrame(payload)
+		if err != nil {
+			return err
+		}
+		if arp.IsIP4() {
+			r.proxyArp(client, arp)
+		}
+		return nil
+	}
+	if !eth.IsIP4() {
+		libol.Log("Router.Input: 0x%04x not IPv4", eth.Type)
+		return nil
+	}
+	ip, err := libol.NewIpv4FromFrame(payload)
+	if err != nil {
+		return err
+	}
+	dest := net.IP(ip.Destination)
+	if dest.IsMulticast() || dest.Equal(net.IPv4bcast) {
+		return nil
+	}
+	if rt := r.findRoute(dest); rt != nil {
+		if rt.client != client {
+			r.toPoint(rt, payload)
+		}
+		return nil
+	}
+	r.lock.RLock()
+	dev := r.device
+	r.lock.RUnlock()
+	if dev == nil {
+		return libol.NewErr("router %s not open", r.name)
+	}
+	_, err = dev.Write(payload)
+	return err
+}
+
+// Read forwards ip packets from the kernel to points.
+func (r *Router) Read() {
+	libol.Info("Router.Read: %s", r.name)
+	data := make([]byte, libol.MAXBUF)
+	for {
+		r.lock.RLock()
+		dev := r.device
+		r.lock.RUnlock()
+		if dev == nil {
+			break
+		}
+		n, err := dev.Read(data)
+		if err != nil {
+			libol.Error("Router.Read: %s", err)
+			break
+		}
+		ip, err := libol.NewIpv4FromFrame(data[:n])
+		if err != nil {
+			continue
+		}
+		rt := r.findRoute(ip.Destination)
+		if rt == nil {
+			libol.Log("Router.Read: %s unreachable", net.IP(ip.Destination))
+			continue
+		}
+		r.toPoint(rt, data[:n])
+	}
+	libol.Info("Router.Read: %s exit", r.name)
+}
+
+func (r *Router) OnClientClose(client libol.SocketClient) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.hosts, client.Addr())
+	for addr, rt := range r.routes {
+		if rt.client == client {
+			delete(r.routes, addr)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package schema
+
+type AclRule struct {
+	Index   int      `json:"index"`
+	Name    string   `json:"name,omitempty"`
+	Users   []string `json:"users,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	SrcMac  string   `json:"srcMac,omitempty"`
+	DstMac  string   `json:"dstMac,omitempty"`
+	Vlan    int      `json:"vlan,omitempty"`
+	Source  string   `json:"source,omitempty"`
+	Dest    string   `json:"destination,omitempty"`
+	Proto   string   `json:"protocol,omitempty"`
+	SrcPort string   `json:"srcPort,omitempty"`
+	DstPort string   `json:"dstPort,omitempty"`
+	Action  string   `json:"action"`
+	Hits    uint64   `json:"hits"`
+}
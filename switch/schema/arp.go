@@ -0,0 +1,7 @@
+package schema
+
+type ArpSts struct {
+	Network    string `json:"network"`
+	Suppressed uint64 `json:"suppressed"`
+	Flooded    uint64 `json:"flooded"`
+}
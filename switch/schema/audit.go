@@ -0,0 +1,19 @@
+package schema
+
+const (
+	AuditApi        = "api"
+	AuditController = "controller"
+)
+
+// Audit is a record of actions, and types are also point.login,
+// point.logout, lease.assign and lease.free.
+type Audit struct {
+	Time    int64  `json:"time"`
+	Type    string `json:"type"`
+	Actor   string `json:"actor,omitempty"`  // user or token.
+	Source  string `json:"source,omitempty"` // address of the actor.
+	Network string `json:"network,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+}
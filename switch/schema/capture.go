@@ -0,0 +1,15 @@
+package schema
+
+type Capture struct {
+	ID       string `json:"id"`
+	Network  string `json:"network"`
+	Point    string `json:"point,omitempty"`
+	Filter   string `json:"filter,omitempty"`
+	Format   string `json:"format,omitempty"` // pcap/pcapng.
+	Count    int    `json:"count"`            // frames at most.
+	Duration int    `json:"duration"`         // seconds at most.
+	Captured int    `json:"captured"`
+	Bytes    int    `json:"bytes"`
+	Start    int64  `json:"start"`
+	Running  bool   `json:"running"`
+}
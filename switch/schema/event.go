@@ -0,0 +1,36 @@
+package schema
+
+const (
+	EventPointLogin     = "point.login"
+	EventPointLogout    = "point.logout"
+	EventLeaseAssign    = "lease.assign"
+	EventLeaseFree      = "lease.free"
+	EventNeighborAdd    = "neighbor.add"
+	EventNeighborMove   = "neighbor.move"
+	EventNeighborExpire = "neighbor.expire"
+	EventLinkUp         = "link.up"
+	EventLinkDown       = "link.down"
+	EventLeaseExhausted = "lease.exhausted"
+	EventFirewallError  = "firewall.error"
+)
+
+// Alerts notified to webhooks.
+const (
+	AlertPointOffline   = "point.offline"
+	AlertPointOnline    = "point.online"
+	AlertLinkOffline    = "link.offline"
+	AlertLinkOnline     = "link.online"
+	AlertAuthFailures   = "auth.failures"
+	AlertLeaseExhausted = EventLeaseExhausted
+	AlertFirewallError  = EventFirewallError
+)
+
+type Event struct {
+	Seq     uint64      `json:"seq"`
+	Type    string      `json:"type"`
+	Time    int64       `json:"time"`
+	Network string      `json:"network,omitempty"`
+	Key     string      `json:"key"`
+	Reason  string      `json:"reason,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
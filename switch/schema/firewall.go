@@ -0,0 +1,18 @@
+package schema
+
+type FireWall struct {
+	Index    int    `json:"index"`
+	Table    string `json:"table"`
+	Chain    string `json:"chain"`
+	Input    string `json:"input,omitempty"`
+	Source   string `json:"source,omitempty"`
+	ToSource string `json:"to-source,omitempty"`
+	Dest     string `json:"destination,omitempty"`
+	ToDest   string `json:"to-destination,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+	Jump     string `json:"jump"`
+	Proto    string `json:"protocol,omitempty"`
+	DstPort  int    `json:"dport,omitempty"`
+	System   bool   `json:"system"`
+}
@@ -0,0 +1,11 @@
+package schema
+
+type Forward struct {
+	Network   string `json:"network"`
+	Protocol  string `json:"protocol"`
+	Port      int    `json:"port"`
+	Point     string `json:"point"`
+	PointPort int    `json:"pointPort"`
+	Address   string `json:"address"` // address of point now.
+	Mode      string `json:"mode"`    // dnat/proxy.
+}
@@ -4,6 +4,7 @@ type Lease struct {
 	Address string `json:"address"`
 	UUID    string `json:"uuid"`
 	Client  string `json:"client"`
+	Network string `json:"network,omitempty"`
 }
 
 type PrefixRoute struct {
@@ -8,4 +8,14 @@ type OnLine struct {
 	IpProto    string `json:"ipProtocol"`
 	PortSource uint16 `json:"portSource"`
 	PortDest   uint16 `json:"portDestination"`
+	User       string `json:"user,omitempty"`
+	Proxy      string `json:"proxy,omitempty"`
+	Network    string `json:"network,omitempty"`
+	Point      string `json:"point,omitempty"`
+	State      string `json:"state,omitempty"`
+	Age        int64  `json:"age"`
+	Packets    uint64 `json:"packets"`
+	Bytes      uint64 `json:"bytes"`
+	RxPackets  uint64 `json:"replyPackets"`
+	RxBytes    uint64 `json:"replyBytes"`
 }
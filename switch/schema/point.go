@@ -5,6 +5,7 @@ type Point struct {
 	UUID    string `json:"uuid"`
 	Network string `json:"network"`
 	Alias   string `json:"alias"`
+	User    string `json:"user,omitempty"`
 	Address string `json:"server"`
 	Switch  string `json:"switch"`
 	IpAddr  string `json:"address"`
@@ -12,5 +13,8 @@ type Point struct {
 	RxBytes uint64 `json:"rxBytes"`
 	TxBytes uint64 `json:"txBytes"`
 	ErrPkt  uint64 `json:"errors"`
+	RxPkt   uint64 `json:"rxFrames"`
+	TxPkt   uint64 `json:"txFrames"`
+	Dropped uint64 `json:"dropped"`
 	State   string `json:"state"`
 }
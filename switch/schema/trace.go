@@ -0,0 +1,37 @@
+package schema
+
+type HookSts struct {
+	Name    string `json:"name"`
+	Passed  uint64 `json:"passed"`
+	Dropped uint64 `json:"dropped"`
+	Errors  uint64 `json:"errors"`
+}
+
+type TraceHook struct {
+	Name   string `json:"name"`
+	Result string `json:"result"` // pass/drop/error.
+	Reason string `json:"reason,omitempty"`
+}
+
+type TraceRecord struct {
+	Time    int64       `json:"time"` // unix nano.
+	Point   string      `json:"point"`
+	Network string      `json:"network"`
+	Frame   string      `json:"frame"`
+	Hooks   []TraceHook `json:"hooks"`
+	Bridge  string      `json:"bridge,omitempty"`
+	Egress  string      `json:"egress,omitempty"`
+}
+
+type Trace struct {
+	ID       string        `json:"id"`
+	Network  string        `json:"network"`
+	Point    string        `json:"point,omitempty"`
+	Filter   string        `json:"filter,omitempty"`
+	Count    int           `json:"count"`    // frames at most.
+	Duration int           `json:"duration"` // seconds at most.
+	Traced   int           `json:"traced"`
+	Start    int64         `json:"start"`
+	Running  bool          `json:"running"`
+	Records  []TraceRecord `json:"records,omitempty"`
+}
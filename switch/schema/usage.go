@@ -0,0 +1,17 @@
+package schema
+
+type UsageSts struct {
+	RxBytes  uint64 `json:"rxBytes"`
+	TxBytes  uint64 `json:"txBytes"`
+	RxFrames uint64 `json:"rxFrames"`
+	TxFrames uint64 `json:"txFrames"`
+	Errors   uint64 `json:"errors"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// Usage is the total of a day by network and user.
+type Usage struct {
+	Date     string               `json:"date"`
+	Networks map[string]*UsageSts `json:"networks"`
+	Users    map[string]*UsageSts `json:"users"`
+}
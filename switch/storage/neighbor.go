@@ -39,6 +39,12 @@ func (p *_neighbor) Update(m *models.Neighbor) *models.Neighbor {
 	return nil
 }
 
+// Move notifies listeners that the neighbor is moved to another client or
+// hardware address.
+func (p *_neighbor) Move(m *models.Neighbor) {
+	_ = p.Listen.AddV(m.IpAddr.String(), m)
+}
+
 func (p *_neighbor) Get(key string) *models.Neighbor {
 	if v := p.Neighbors.Get(key); v != nil {
 		return v.(*models.Neighbor)
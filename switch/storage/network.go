@@ -12,12 +12,16 @@ type _network struct {
 	Networks *libol.SafeStrMap
 	AddrUUID *libol.SafeStrStr // TODO with network
 	UUIDAddr *libol.SafeStrStr // TODO with network
+	Listen   Listen            // notified with leases.
 }
 
 var Network = _network{
 	Networks: libol.NewSafeStrMap(1024),
 	AddrUUID: libol.NewSafeStrStr(1024),
 	UUIDAddr: libol.NewSafeStrStr(1024),
+	Listen: Listen{
+		listener: libol.NewSafeStrMap(32),
+	},
 }
 
 func (w *_network) Add(n *models.Network) {
@@ -68,10 +72,23 @@ func (w *_network) ListLease() <-chan *schema.Lease {
 	return c
 }
 
+func (w *_network) addLease(uuid, ipStr, network string) {
+	_ = w.Listen.AddV(uuid, &schema.Lease{
+		Address: ipStr,
+		UUID:    uuid,
+		Client:  Point.GetAddr(uuid),
+		Network: network,
+	})
+}
+
 func (w *_network) AddUsedAddr(uuid, ipStr string) {
 	if ipStr != "" {
+		older := w.UUIDAddr.Get(uuid)
 		_ = w.AddrUUID.Set(ipStr, uuid)
 		_ = w.UUIDAddr.Set(uuid, ipStr)
+		if older != ipStr {
+			w.addLease(uuid, ipStr, "")
+		}
 	}
 }
 
@@ -104,13 +121,22 @@ func (w *_network) GetFreeAddr(uuid string, n *models.Network) (ip string, mask
 	if ipStr != "" {
 		_ = w.AddrUUID.Set(ipStr, uuid)
 		_ = w.UUIDAddr.Set(uuid, ipStr)
+		w.addLease(uuid, ipStr, n.Name)
+	} else {
+		// notify listeners with the network if no free address.
+		_ = w.Listen.AddV(uuid, n)
 	}
 	return ipStr, netmask
 }
 
+func (w *_network) GetAddr(uuid string) string {
+	return w.UUIDAddr.Get(uuid)
+}
+
 func (w *_network) FreeAddr(uuid string) {
 	if addr, ok := w.UUIDAddr.GetEx(uuid); ok {
 		w.UUIDAddr.Del(uuid)
 		w.AddrUUID.Del(addr)
+		w.Listen.DelV(uuid)
 	}
 }
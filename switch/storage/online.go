@@ -3,6 +3,7 @@ package storage
 import (
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/models"
+	"sync/atomic"
 )
 
 type _online struct {
@@ -24,7 +25,7 @@ func (p *_online) Add(m *models.Line) {
 func (p *_online) Update(m *models.Line) *models.Line {
 	if v := p.Lines.Get(m.String()); v != nil {
 		l := v.(*models.Line)
-		l.HitTime = m.HitTime
+		atomic.StoreInt64(&l.HitTime, atomic.LoadInt64(&m.HitTime))
 	}
 	return nil
 }
@@ -2,13 +2,16 @@ package _switch
 
 import (
 	"encoding/json"
+	"github.com/danieldin95/openlan-go/controller/libctrl"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/network"
 	"github.com/danieldin95/openlan-go/switch/app"
 	"github.com/danieldin95/openlan-go/switch/ctrls"
+	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -44,6 +47,8 @@ func GetSocketServer(c config.Switch) libol.SocketServer {
 
 type Apps struct {
 	Auth     *app.PointAuth
+	Guard    *app.SourceGuard
+	Acl      *app.Acl
 	Request  *app.WithRequest
 	Neighbor *app.Neighbors
 	OnLines  *app.Online
@@ -57,10 +62,23 @@ type Switch struct {
 	cfg      config.Switch
 	apps     Apps
 	firewall FireWall
+	forward  *Forwarder
+	account  *Accounting
+	export   *FlowExporter
+	capture  *Capturer
+	tracer   *Tracer
+	events   *Events
+	alert    *Alerter
+	audit    *Auditor
+	peering  Peering
 	hooks    []Hook
 	http     *Http
 	server   libol.SocketServer
 	bridge   map[string]network.Bridger
+	router   map[string]*Router
+	dhcp     map[string]*DhcpServer
+	dns      map[string]*DnsServer
+	proxy    map[string]*ProxyServer
 	worker   map[string]*NetworkWorker
 	uuid     string
 	newTime  int64
@@ -69,35 +87,50 @@ type Switch struct {
 func NewSwitch(c config.Switch) *Switch {
 	server := GetSocketServer(c)
 	v := Switch{
-		cfg: c,
-		firewall: FireWall{
-			rules: make([]libol.FilterRule, 0, 32),
+		cfg:      c,
+		firewall: NewFireWall(c.Backend),
+		peering: Peering{
+			routes: make([]config.PrefixRoute, 0, 32),
 		},
 		worker:  make(map[string]*NetworkWorker, 32),
 		bridge:  make(map[string]network.Bridger, 32),
+		router:  make(map[string]*Router, 32),
+		dhcp:    make(map[string]*DhcpServer, 32),
+		dns:     make(map[string]*DnsServer, 32),
+		proxy:   make(map[string]*ProxyServer, 32),
+		capture: NewCapturer(),
+		tracer:  NewTracer(),
+		events:  NewEvents(),
 		server:  server,
 		newTime: time.Now().Unix(),
 	}
+	v.firewall.OnError = func(err error) {
+		v.events.Publish(schema.Event{
+			Type:   schema.EventFirewallError,
+			Key:    "firewall",
+			Reason: err.Error(),
+		})
+	}
 	return &v
 }
 
 func (v *Switch) addRules(source string, prefix string) {
 	libol.Info("Switch.addRules %s, %s", source, prefix)
-	v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
+	v.firewall.AddSystem(libol.FilterRule{
 		Table:  "filter",
 		Chain:  "FORWARD",
 		Source: source,
 		Dest:   prefix,
 		Jump:   "ACCEPT",
 	})
-	v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
+	v.firewall.AddSystem(libol.FilterRule{
 		Table:  "nat",
 		Chain:  "POSTROUTING",
 		Source: source,
 		Dest:   prefix,
 		Jump:   "MASQUERADE",
 	})
-	v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
+	v.firewall.AddSystem(libol.FilterRule{
 		Table:  "nat",
 		Chain:  "POSTROUTING",
 		Dest:   source,
@@ -106,6 +139,90 @@ func (v *Switch) addRules(source string, prefix string) {
 	})
 }
 
+func (v *Switch) findNetwork(name string) *config.Network {
+	for _, nCfg := range v.cfg.Network {
+		if nCfg.Name == name {
+			return nCfg
+		}
+	}
+	return nil
+}
+
+// addPeers pushes routes of peer prefixes to points, and accepts forwarding
+// between this network and its peers. Other forwarding between subnets of
+// peers is dropped by addDrops after accepts of all networks.
+func (v *Switch) addPeers(nCfg *config.Network) {
+	source := nCfg.Bridge.Address
+	for _, peer := range nCfg.Peers {
+		pCfg := v.findNetwork(peer.Network)
+		if pCfg == nil || pCfg == nCfg {
+			libol.Warn("Switch.addPeers %s: invalid peer %s", nCfg.Name, peer.Network)
+			continue
+		}
+		if source == "" || pCfg.Bridge.Address == "" {
+			libol.Warn("Switch.addPeers %s: %s without address", nCfg.Name, peer.Network)
+			continue
+		}
+		ifAddr := strings.SplitN(source, "/", 2)[0]
+		peerAddr := strings.SplitN(pCfg.Bridge.Address, "/", 2)[0]
+		_, subnet, err := net.ParseCIDR(source)
+		_, peerNet, peerErr := net.ParseCIDR(pCfg.Bridge.Address)
+		if err != nil || peerErr != nil {
+			libol.Warn("Switch.addPeers %s: invalid address of %s", nCfg.Name, peer.Network)
+			continue
+		}
+		prefixes := peer.Prefixes
+		if len(prefixes) == 0 {
+			prefixes = []string{peerNet.String()}
+		}
+		v.peering.addDrop(subnet.String(), peerNet.String())
+		for _, prefix := range prefixes {
+			libol.Info("Switch.addPeers %s to %s on %s", nCfg.Name, prefix, peer.Network)
+			nCfg.Routes = append(nCfg.Routes, config.PrefixRoute{
+				Prefix:  prefix,
+				NextHop: ifAddr,
+			})
+			for _, rt := range pCfg.Routes {
+				if rt.Prefix == prefix && rt.NextHop != "" && rt.NextHop != peerAddr {
+					v.peering.routes = append(v.peering.routes, rt)
+				}
+			}
+			v.firewall.AddSystem(libol.FilterRule{
+				Table:  "filter",
+				Chain:  "FORWARD",
+				Source: source,
+				Dest:   prefix,
+				Jump:   "ACCEPT",
+			})
+			v.firewall.AddSystem(libol.FilterRule{
+				Table:  "filter",
+				Chain:  "FORWARD",
+				Source: prefix,
+				Dest:   source,
+				Jump:   "ACCEPT",
+			})
+			if peer.Nat {
+				v.firewall.AddSystem(libol.FilterRule{
+					Table:  "nat",
+					Chain:  "POSTROUTING",
+					Source: source,
+					Dest:   prefix,
+					Jump:   "MASQUERADE",
+				})
+			}
+		}
+	}
+}
+
+// addDrops drops forwarding between peered subnets, which must be added
+// after rules accepted by all networks.
+func (v *Switch) addDrops() {
+	for _, rule := range v.peering.drops {
+		libol.Info("Switch.addDrops %s to %s", rule.Source, rule.Dest)
+		v.firewall.AddSystem(rule)
+	}
+}
+
 func (v *Switch) Initialize() {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -133,20 +250,53 @@ func (v *Switch) Initialize() {
 				v.addRules(source, rt.Prefix)
 			}
 		}
+		v.addPeers(nCfg)
 		v.worker[name] = NewNetworkWorker(*nCfg, crypt)
-		v.bridge[name] = network.NewBridger(brCfg.Provider, brCfg.Name, brCfg.IfMtu)
+		if dns := nCfg.Dns; dns != nil && dns.Enable {
+			v.dns[name] = NewDnsServer(*nCfg)
+		}
+		if proxy := nCfg.Proxy; proxy != nil && proxy.Enable {
+			v.proxy[name] = NewProxyServer(*nCfg)
+		}
+		if nCfg.IsRouted() {
+			v.router[name] = NewRouter(*nCfg)
+			continue
+		}
+		if dhcp := nCfg.Dhcp; dhcp != nil && dhcp.Enable {
+			if brCfg.Provider == "linux" {
+				v.dhcp[name] = NewDhcpServer(*nCfg)
+			} else {
+				libol.Warn("Switch.Initialize: dhcp not support on %s", brCfg.Provider)
+			}
+		}
+		br := network.NewBridger(brCfg.Provider, brCfg.Name, brCfg.IfMtu)
+		if iso := nCfg.Isolation; iso != nil {
+			br.SetIsolation(iso.Enable, iso.Uplinks)
+		}
+		if storm := brCfg.Storm; storm != nil {
+			br.SetStorm(network.StormLimit(storm.Port), network.StormLimit(storm.Bridge))
+		}
+		if snoop := brCfg.Snooping; snoop != nil {
+			br.SetSnooping(snoop.Enable, snoop.Timeout)
+		}
+		v.bridge[name] = br
 	}
+	v.addDrops()
 
 	v.apps.Auth = app.NewPointAuth(v, v.cfg)
+	v.apps.Guard = app.NewSourceGuard(v, v.cfg)
+	v.apps.Acl = app.NewAcl(v, v.cfg)
 	v.apps.Request = app.NewWithRequest(v, v.cfg)
 	v.apps.Neighbor = app.NewNeighbors(v, v.cfg)
 	v.apps.OnLines = app.NewOnline(v, v.cfg)
 
 	v.hooks = make([]Hook, 0, 64)
-	v.hooks = append(v.hooks, v.apps.Auth.OnFrame)
-	v.hooks = append(v.hooks, v.apps.Neighbor.OnFrame)
-	v.hooks = append(v.hooks, v.apps.Request.OnFrame)
-	v.hooks = append(v.hooks, v.apps.OnLines.OnFrame)
+	v.addHook("auth", v.apps.Auth.OnFrame)
+	v.addHook("guard", v.apps.Guard.OnFrame)
+	v.addHook("acl", v.apps.Acl.OnFrame)
+	v.addHook("neighbor", v.apps.Neighbor.OnFrame)
+	v.addHook("request", v.apps.Request.OnFrame)
+	v.addHook("online", v.apps.OnLines.OnFrame)
 	for i, h := range v.hooks {
 		libol.Debug("Switch.Initialize: k %d, func %p, %s", i, h, libol.FunName(h))
 	}
@@ -157,15 +307,18 @@ func (v *Switch) Initialize() {
 		ctrls.Ctrl.Name = v.cfg.Alias
 	}
 	ctrls.Ctrl.Switcher = v
+	ctrls.Ctrl.OnCmd = v.onCtrlCmd
 
 	// FireWall
 	for _, rule := range v.cfg.FireWall {
-		v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
+		v.firewall.Load(libol.FilterRule{
 			Table:    rule.Table,
 			Chain:    rule.Chain,
 			Source:   rule.Source,
 			Dest:     rule.Dest,
 			Jump:     rule.Jump,
+			Proto:    rule.Proto,
+			DstPort:  rule.DstPort,
 			ToSource: rule.ToSource,
 			ToDest:   rule.ToDest,
 			Comment:  rule.Comment,
@@ -173,14 +326,54 @@ func (v *Switch) Initialize() {
 			Output:   rule.Output,
 		})
 	}
-	libol.Info("Switch.Initialize total %d rules", len(v.firewall.rules))
+	libol.Info("Switch.Initialize total %d rules", len(v.firewall.List()))
+
+	// Forward
+	v.forward = NewForwarder(&v.cfg, &v.firewall)
+	for _, fCfg := range v.cfg.Forward {
+		if err := v.forward.Add(fCfg); err != nil {
+			libol.Warn("Switch.Initialize %s", err)
+		}
+	}
+
+	// Accounting
+	v.account = NewAccounting(v.cfg.ConfDir + "/usage.json")
+
+	// Export
+	if eCfg := v.cfg.Export; eCfg != nil && eCfg.Enable {
+		v.export = NewFlowExporter(*eCfg)
+	}
+	// Audit
+	aCfg := config.Audit{}
+	if v.cfg.Audit != nil {
+		aCfg = *v.cfg.Audit
+	}
+	if aCfg.File == "" {
+		aCfg.File = v.cfg.ConfDir + "/audit.log"
+	}
+	v.audit = NewAuditor(aCfg, v.events)
+	// Alert
+	if aCfg := v.cfg.Alert; aCfg != nil && len(aCfg.Webhooks) > 0 {
+		v.alert = NewAlerter(*aCfg, v.events, func() int {
+			_, failed := v.apps.Auth.Stats()
+			return failed
+		})
+	}
+}
+
+func (v *Switch) addHook(name string, h Hook) {
+	v.hooks = append(v.hooks, h)
+	v.tracer.AddHook(name)
 }
 
-func (v *Switch) onFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
-	for _, h := range v.hooks {
+func (v *Switch) onFrame(client libol.SocketClient, frame *libol.FrameMessage, trace *frameTrace) error {
+	for i, h := range v.hooks {
 		libol.Log("Switch.onFrame: h %p", h)
 		if h != nil {
-			if err := h(client, frame); err != nil {
+			err := h(client, frame)
+			v.tracer.Count(i, err)
+			trace.Hook(v.tracer.HookName(i), err)
+			if err != nil {
 				return err
 			}
 		}
@@ -188,6 +381,28 @@ func (v *Switch) onFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	return nil
 }
 
+// forwardTo returns the decision of bridge for the frame from dev.
+func (v *Switch) forwardTo(name string, dev network.Taper, data []byte) string {
+	br, ok := v.bridge[name]
+	if !ok {
+		return ""
+	}
+	vb, ok := br.(*network.VirtualBridge)
+	if !ok {
+		return "kernel " + br.Name()
+	}
+	out, isolated := vb.Lookup(dev, data)
+	switch {
+	case out == nil:
+		return "flood"
+	case isolated:
+		return "isolated from " + out.Name()
+	case out == dev:
+		return "local"
+	}
+	return "unicast to " + out.Name()
+}
+
 func (v *Switch) OnClient(client libol.SocketClient) error {
 	client.SetStatus(libol.ClConnected)
 	libol.Info("Switch.onClient: %s", client.Addr())
@@ -219,9 +434,19 @@ func (v *Switch) SignIn(client libol.SocketClient) error {
 func (v *Switch) ReadClient(client libol.SocketClient, data []byte) error {
 	libol.Log("Switch.ReadClient: %s %x", client.Addr(), data)
 	frame := libol.NewFrameMessage(data)
-	if err := v.onFrame(client, frame); err != nil {
-		libol.Debug("Switch.ReadClient: %s dropping by %s", client.Addr(), err)
+	if point, ok := client.Private().(*models.Point); ok && point != nil && !frame.IsControl() {
+		v.capture.Capture(point.Network, point.UUID, "", frame.Data())
+	}
+	trace := v.tracer.Begin(client, frame)
+	defer v.tracer.End(trace)
+	if err := v.onFrame(client, frame, trace); err != nil {
+		if app.IsDropped(err) {
+			logOf(client).Log("Switch.ReadClient: %s", err)
+			return nil
+		}
+		logOf(client).Debug("Switch.ReadClient: dropping by %s", err)
 		// send request to point login again.
+		trace.Forward("", "signin")
 		_ = v.SignIn(client)
 		return nil
 	}
@@ -232,10 +457,20 @@ func (v *Switch) ReadClient(client libol.SocketClient, data []byte) error {
 	private := client.Private()
 	if private != nil {
 		point := private.(*models.Point)
+		if r, ok := v.router[point.Network]; ok {
+			trace.Forward("router", "router "+point.Network)
+			if err := r.Input(client, data); err != nil {
+				libol.Debug("Switch.ReadClient: %s %s", client.Addr(), err)
+			}
+			return nil
+		}
 		dev := point.Device
 		if point == nil || dev == nil {
 			return libol.NewErr("Tap devices is nil")
 		}
+		if trace != nil {
+			trace.Forward(v.forwardTo(point.Network, dev, data), dev.Name())
+		}
 		if _, err := dev.Write(data); err != nil {
 			libol.Error("Switch.ReadClient: %s", err)
 			return err
@@ -245,14 +480,30 @@ func (v *Switch) ReadClient(client libol.SocketClient, data []byte) error {
 	return libol.NewErr("point %s not found.", client)
 }
 
+// logOf returns an entry of logger with fields of the client.
+func logOf(client libol.SocketClient) *libol.Entry {
+	entry := libol.With("client", client.Addr())
+	if point, ok := client.Private().(*models.Point); ok && point != nil {
+		entry = entry.With("network", point.Network, "uuid", point.UUID)
+	}
+	return entry
+}
+
 func (v *Switch) OnClose(client libol.SocketClient) error {
-	libol.Info("Switch.OnClose: %s", client.Addr())
+	logOf(client).Info("Switch.OnClose: %s", client.Addr())
 
+	v.account.OnClientClose(client)
 	uuid := storage.Point.GetUUID(client.Addr())
 	if storage.Point.GetAddr(uuid) == client.Addr() { // not has newer
 		storage.Network.FreeAddr(uuid)
 	}
 	storage.Point.Del(client.Addr())
+	if v.apps.Guard != nil {
+		v.apps.Guard.OnClientClose(client)
+	}
+	for _, r := range v.router {
+		r.OnClientClose(client)
+	}
 
 	return nil
 }
@@ -262,11 +513,24 @@ func (v *Switch) Start() {
 	defer v.lock.Unlock()
 
 	libol.Debug("Switch.Start")
+	v.events.Register()
 	for _, nCfg := range v.cfg.Network {
 		if br, ok := v.bridge[nCfg.Name]; ok {
 			brCfg := nCfg.Bridge
 			br.Open(brCfg.Address)
 		}
+		if r, ok := v.router[nCfg.Name]; ok {
+			r.Open()
+		}
+		if d, ok := v.dhcp[nCfg.Name]; ok {
+			d.Start()
+		}
+		if d, ok := v.dns[nCfg.Name]; ok {
+			d.Start()
+		}
+		if p, ok := v.proxy[nCfg.Name]; ok {
+			p.Start()
+		}
 	}
 	libol.Go(v.server.Accept)
 	call := libol.ServerListener{
@@ -283,6 +547,16 @@ func (v *Switch) Start() {
 	}
 	libol.Go(ctrls.Ctrl.Start)
 	libol.Go(v.firewall.Start)
+	libol.Go(v.forward.Start)
+	libol.Go(v.account.Start)
+	if v.export != nil {
+		libol.Go(v.export.Start)
+	}
+	v.audit.Start()
+	if v.alert != nil {
+		v.alert.Start()
+	}
+	libol.Go(v.peering.Start)
 }
 
 func (v *Switch) Stop() {
@@ -299,7 +573,18 @@ func (v *Switch) Stop() {
 		}
 		v.leftClient(p.Client)
 	}
+	if v.export != nil {
+		v.export.Stop()
+	}
+	if v.alert != nil {
+		v.alert.Stop()
+	}
+	v.audit.Stop()
+	v.account.Stop()
+	v.events.Unregister()
+	v.forward.Stop()
 	v.firewall.Stop()
+	v.peering.Stop()
 	ctrls.Ctrl.Stop()
 	if v.http != nil {
 		v.http.Shutdown()
@@ -309,11 +594,23 @@ func (v *Switch) Stop() {
 		w.Stop()
 	}
 	for _, nCfg := range v.cfg.Network {
+		if d, ok := v.dhcp[nCfg.Name]; ok {
+			d.Stop()
+		}
+		if d, ok := v.dns[nCfg.Name]; ok {
+			d.Stop()
+		}
+		if p, ok := v.proxy[nCfg.Name]; ok {
+			p.Stop()
+		}
 		if br, ok := v.bridge[nCfg.Name]; ok {
 			brCfg := nCfg.Bridge
 			_ = br.Close()
 			delete(v.bridge, brCfg.Name)
 		}
+		if r, ok := v.router[nCfg.Name]; ok {
+			r.Close()
+		}
 	}
 	v.server.Close()
 }
@@ -335,6 +632,10 @@ func (v *Switch) NewTap(tenant string) (network.Taper, error) {
 	defer v.lock.Unlock()
 	libol.Debug("Switch.NewTap")
 
+	// points attach to the router without device in routed network.
+	if _, ok := v.router[tenant]; ok {
+		return nil, nil
+	}
 	br, ok := v.bridge[tenant]
 	if !ok {
 		return nil, libol.NewErr("Not found bridge %s", tenant)
@@ -389,6 +690,7 @@ func (v *Switch) ReadTap(dev network.Taper, readAt func(p []byte) error) {
 			break
 		}
 		libol.Log("Switch.ReadTap: %x\n", data[:n])
+		v.capture.Capture(dev.Tenant(), "", dev.Name(), data[:n])
 		if err := readAt(data[:n]); err != nil {
 			libol.Error("Switch.ReadTap: do-recv %s %s", dev.Name(), err)
 			break
@@ -396,9 +698,10 @@ func (v *Switch) ReadTap(dev network.Taper, readAt func(p []byte) error) {
 	}
 }
 
-func (v *Switch) OffClient(client libol.SocketClient) {
-	libol.Info("Switch.OffClient: %s", client)
+func (v *Switch) OffClient(client libol.SocketClient, reason string) {
+	libol.Info("Switch.OffClient: %s by %s", client, reason)
 	if v.server != nil {
+		v.events.Reason(client.Addr(), reason)
 		v.server.OffClient(client)
 	}
 }
@@ -407,6 +710,311 @@ func (v *Switch) Config() *config.Switch {
 	return &v.cfg
 }
 
+func (v *Switch) StormSts(name string) []network.StormSts {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if br, ok := v.bridge[name]; ok {
+		return br.StormSts()
+	}
+	return nil
+}
+
+func (v *Switch) McastMembers(name string) []network.McastMember {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if br, ok := v.bridge[name]; ok {
+		return br.McastMembers()
+	}
+	return nil
+}
+
+func (v *Switch) ArpSts() []schema.ArpSts {
+	if v.apps.Neighbor == nil {
+		return nil
+	}
+	return v.apps.Neighbor.Stats()
+}
+
+func (v *Switch) AclRules(name string) []schema.AclRule {
+	if v.apps.Acl == nil {
+		return nil
+	}
+	return v.apps.Acl.Rules(name)
+}
+
+// Metrics adds samples of the switch and its applications.
+func (v *Switch) Metrics(m *libol.Metrics) {
+	m.Add("openlan_switch_uptime_seconds", libol.MetricGauge,
+		"Seconds since the switch started.", v.UpTime())
+
+	sts := v.server.Sts()
+	m.Add("openlan_switch_accepted_total", libol.MetricCounter,
+		"Connections accepted.", sts.AcceptCount)
+	m.Add("openlan_switch_closed_total", libol.MetricCounter,
+		"Connections closed.", sts.CloseCount)
+	m.Add("openlan_switch_received_frames_total", libol.MetricCounter,
+		"Frames received from all connections.", sts.RecvCount)
+	m.Add("openlan_switch_dropped_frames_total", libol.MetricCounter,
+		"Frames dropped by the server.", sts.DropCount)
+	if v.apps.Auth != nil {
+		success, failed := v.apps.Auth.Stats()
+		m.Add("openlan_switch_auth_total", libol.MetricCounter,
+			"Logins of points by result.", success, "result", "success")
+		m.Add("openlan_switch_auth_total", libol.MetricCounter,
+			"Logins of points by result.", failed, "result", "failed")
+	}
+	if v.apps.Guard != nil {
+		m.Add("openlan_switch_guard_dropped_total", libol.MetricCounter,
+			"Frames dropped by source guard.", v.apps.Guard.Stats())
+	}
+	if v.apps.Acl != nil {
+		m.Add("openlan_switch_acl_dropped_total", libol.MetricCounter,
+			"Frames denied by acl.", v.apps.Acl.Stats())
+	}
+	for _, h := range v.tracer.HookSts() {
+		m.Add("openlan_switch_hook_frames_total", libol.MetricCounter,
+			"Frames by hooks and results.", h.Passed, "hook", h.Name, "result", "pass")
+		m.Add("openlan_switch_hook_frames_total", libol.MetricCounter,
+			"Frames by hooks and results.", h.Dropped, "hook", h.Name, "result", "drop")
+		m.Add("openlan_switch_hook_frames_total", libol.MetricCounter,
+			"Frames by hooks and results.", h.Errors, "hook", h.Name, "result", "error")
+	}
+	for _, arp := range v.ArpSts() {
+		m.Add("openlan_switch_arp_total", libol.MetricCounter,
+			"ARP requests by result.", arp.Suppressed, "network", arp.Network, "result", "suppressed")
+		m.Add("openlan_switch_arp_total", libol.MetricCounter,
+			"ARP requests by result.", arp.Flooded, "network", arp.Network, "result", "flooded")
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	for name, br := range v.bridge {
+		for _, sts := range br.StormSts() {
+			kinds := map[string]uint64{
+				"broadcast": sts.Broadcast,
+				"multicast": sts.Multicast,
+				"unknown":   sts.Unknown,
+			}
+			for _, kind := range []string{"broadcast", "multicast", "unknown"} {
+				m.Add("openlan_switch_storm_dropped_total", libol.MetricCounter,
+					"Flooded frames dropped by storm control.", kinds[kind],
+					"network", name, "device", sts.Device, "kind", kind)
+			}
+		}
+	}
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		tap, ok := p.Device.(*network.UserSpaceTap)
+		if !ok {
+			continue
+		}
+		read, write := tap.QueueLen()
+		m.Add("openlan_switch_queue_length", libol.MetricGauge,
+			"Frames waiting in queues of devices.", read,
+			"network", p.Network, "device", tap.Name(), "queue", "read")
+		m.Add("openlan_switch_queue_length", libol.MetricGauge,
+			"Frames waiting in queues of devices.", write,
+			"network", p.Network, "device", tap.Name(), "queue", "write")
+	}
+}
+
+func (v *Switch) ListRule() []schema.FireWall {
+	return v.firewall.List()
+}
+
+// saveRules persists rules from user into the firewall of configuration,
+// and must be called with the lock.
+func (v *Switch) saveRules() error {
+	rules := v.firewall.Rules()
+	v.cfg.FireWall = make([]config.FlowRules, 0, len(rules))
+	for _, rule := range rules {
+		v.cfg.FireWall = append(v.cfg.FireWall, config.FlowRules{
+			Table:    rule.Table,
+			Chain:    rule.Chain,
+			Input:    rule.Input,
+			Source:   rule.Source,
+			ToSource: rule.ToSource,
+			Dest:     rule.Dest,
+			ToDest:   rule.ToDest,
+			Output:   rule.Output,
+			Comment:  rule.Comment,
+			Jump:     rule.Jump,
+			Proto:    rule.Proto,
+			DstPort:  rule.DstPort,
+		})
+	}
+	return v.cfg.SaveFireWall()
+}
+
+func (v *Switch) AddRule(rule schema.FireWall, index int) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.firewall.Add(libol.FilterRule{
+		Table:    rule.Table,
+		Chain:    rule.Chain,
+		Input:    rule.Input,
+		Source:   rule.Source,
+		ToSource: rule.ToSource,
+		Dest:     rule.Dest,
+		ToDest:   rule.ToDest,
+		Output:   rule.Output,
+		Comment:  rule.Comment,
+		Jump:     rule.Jump,
+		Proto:    rule.Proto,
+		DstPort:  rule.DstPort,
+	}, index); err != nil {
+		return err
+	}
+	return v.saveRules()
+}
+
+func (v *Switch) DelRule(index int) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.firewall.Del(index); err != nil {
+		return err
+	}
+	return v.saveRules()
+}
+
+func (v *Switch) MoveRule(from, to int) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.firewall.Move(from, to); err != nil {
+		return err
+	}
+	return v.saveRules()
+}
+
+func (v *Switch) ListForward() []schema.Forward {
+	return v.forward.List()
+}
+
+func (v *Switch) AddForward(fwd schema.Forward) error {
+	if err := v.forward.Add(config.Forward{
+		Network:   fwd.Network,
+		Protocol:  fwd.Protocol,
+		Port:      fwd.Port,
+		Point:     fwd.Point,
+		PointPort: fwd.PointPort,
+	}); err != nil {
+		return err
+	}
+	v.cfg.Forward = v.forward.Configs()
+	return v.cfg.SaveForward()
+}
+
+func (v *Switch) DelForward(protocol string, port int) error {
+	if err := v.forward.Del(protocol, port); err != nil {
+		return err
+	}
+	v.cfg.Forward = v.forward.Configs()
+	return v.cfg.SaveForward()
+}
+
+func (v *Switch) ListUsage() []schema.Usage {
+	return v.account.List()
+}
+
+func (v *Switch) GetUsage(date string) *schema.Usage {
+	return v.account.Get(date)
+}
+
+func (v *Switch) ListCapture() []schema.Capture {
+	return v.capture.List()
+}
+
+func (v *Switch) GetCapture(id string) *schema.Capture {
+	return v.capture.Get(id)
+}
+
+func (v *Switch) AddCapture(c schema.Capture) (schema.Capture, error) {
+	found := false
+	for _, nCfg := range v.cfg.Network {
+		if nCfg.Name == c.Network {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c, libol.NewErr("network %s not found", c.Network)
+	}
+	return v.capture.Add(c)
+}
+
+func (v *Switch) DelCapture(id string) error {
+	return v.capture.Del(id)
+}
+
+func (v *Switch) CapturePcap(id string) ([]byte, string, error) {
+	return v.capture.Pcap(id)
+}
+
+func (v *Switch) HookSts() []schema.HookSts {
+	return v.tracer.HookSts()
+}
+
+func (v *Switch) ListTrace() []schema.Trace {
+	return v.tracer.List()
+}
+
+func (v *Switch) GetTrace(id string) *schema.Trace {
+	return v.tracer.Get(id)
+}
+
+func (v *Switch) AddTrace(t schema.Trace) (schema.Trace, error) {
+	for _, nCfg := range v.cfg.Network {
+		if nCfg.Name == t.Network {
+			return v.tracer.Add(t)
+		}
+	}
+	return t, libol.NewErr("network %s not found", t.Network)
+}
+
+func (v *Switch) DelTrace(id string) error {
+	return v.tracer.Del(id)
+}
+
+func (v *Switch) ListEvent(since uint64) []schema.Event {
+	return v.events.List(since)
+}
+
+func (v *Switch) SubscribeEvent(since int64) (int, []schema.Event, <-chan schema.Event) {
+	return v.events.Subscribe(since)
+}
+
+func (v *Switch) UnsubscribeEvent(id int) {
+	v.events.Unsubscribe(id)
+}
+
+func (v *Switch) AddAudit(record schema.Audit) {
+	v.audit.Add(record)
+}
+
+func (v *Switch) ListAudit(start, end int64, kind string, limit int) ([]schema.Audit, error) {
+	return v.audit.List(start, end, kind, limit)
+}
+
+// onCtrlCmd audits commands from the controller, and data is cut off at
+// 256 bytes.
+func (v *Switch) onCtrlCmd(id string, m libctrl.Message) {
+	if len(m.Data) > 256 {
+		m.Data = m.Data[:256] + "..."
+	}
+	v.audit.Add(schema.Audit{
+		Type:   schema.AuditController,
+		Actor:  id,
+		Source: ctrls.Ctrl.Url,
+		Action: m.Action,
+		Target: m.Resource,
+		Detail: m.Data,
+	})
+}
+
 func (v *Switch) leftClient(client libol.SocketClient) {
 	if client == nil {
 		return
@@ -0,0 +1,300 @@
+package _switch
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/app"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	traceMaxSessions = 16
+	traceMaxCount    = 1000
+	traceMaxDuration = 600
+)
+
+// String returns the summary of the frame like tcpdump.
+func (f *captureFrame) String() string {
+	summary := fmt.Sprintf("%s > %s", net.HardwareAddr(f.eth.Src), net.HardwareAddr(f.eth.Dst))
+	switch {
+	case f.ethType == libol.EthArp && f.source != nil:
+		return fmt.Sprintf("%s arp %s > %s", summary, net.IP(f.source), net.IP(f.dest))
+	case f.proto == libol.IpTcp || f.proto == libol.IpUdp:
+		return fmt.Sprintf("%s %s %s:%d > %s:%d", summary, libol.IpProto2Str(uint8(f.proto)),
+			f.source, f.srcPort, f.dest, f.dstPort)
+	case f.proto >= 0:
+		return fmt.Sprintf("%s %s %s > %s", summary, libol.IpProto2Str(uint8(f.proto)), f.source, f.dest)
+	}
+	return fmt.Sprintf("%s 0x%04x", summary, f.ethType)
+}
+
+type hookSts struct {
+	name    string
+	passed  uint64
+	dropped uint64
+	errors  uint64
+}
+
+type traceSession struct {
+	cfg     schema.Trace
+	terms   []*captureTerm
+	timer   *time.Timer
+	running bool
+}
+
+func (s *traceSession) Match(network, point string, f *captureFrame) bool {
+	if s.cfg.Network != network {
+		return false
+	}
+	if s.cfg.Point != "" && s.cfg.Point != point {
+		return false
+	}
+	for _, t := range s.terms {
+		if !t.Match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *traceSession) Schema(records bool) schema.Trace {
+	c := s.cfg
+	c.Running = s.running
+	if !records {
+		c.Records = nil
+	}
+	return c
+}
+
+// frameTrace is decisions for a frame, and methods do nothing if nil.
+type frameTrace struct {
+	record   schema.TraceRecord
+	sessions []*traceSession
+}
+
+func (t *frameTrace) Hook(name string, err error) {
+	if t == nil {
+		return
+	}
+	h := schema.TraceHook{Name: name, Result: "pass"}
+	if err != nil {
+		h.Reason = err.Error()
+		if app.IsDropped(err) {
+			h.Result = "drop"
+		} else {
+			h.Result = "error"
+		}
+	}
+	t.record.Hooks = append(t.record.Hooks, h)
+}
+
+func (t *frameTrace) Forward(bridge, egress string) {
+	if t == nil {
+		return
+	}
+	t.record.Bridge = bridge
+	t.record.Egress = egress
+}
+
+// Tracer counts results of each hook, and records decisions for frames
+// matched by sessions.
+type Tracer struct {
+	lock     sync.Mutex
+	hooks    []*hookSts
+	sessions map[string]*traceSession
+	active   int32
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{
+		hooks:    make([]*hookSts, 0, 16),
+		sessions: make(map[string]*traceSession, traceMaxSessions),
+	}
+}
+
+// AddHook registers name of the hook in order of the pipeline.
+func (t *Tracer) AddHook(name string) {
+	t.hooks = append(t.hooks, &hookSts{name: name})
+}
+
+func (t *Tracer) HookName(index int) string {
+	return t.hooks[index].name
+}
+
+// Count counts the result of hook at index.
+func (t *Tracer) Count(index int, err error) {
+	h := t.hooks[index]
+	if err == nil {
+		atomic.AddUint64(&h.passed, 1)
+	} else if app.IsDropped(err) {
+		atomic.AddUint64(&h.dropped, 1)
+	} else {
+		atomic.AddUint64(&h.errors, 1)
+	}
+}
+
+func (t *Tracer) HookSts() []schema.HookSts {
+	sts := make([]schema.HookSts, 0, len(t.hooks))
+	for _, h := range t.hooks {
+		sts = append(sts, schema.HookSts{
+			Name:    h.name,
+			Passed:  atomic.LoadUint64(&h.passed),
+			Dropped: atomic.LoadUint64(&h.dropped),
+			Errors:  atomic.LoadUint64(&h.errors),
+		})
+	}
+	return sts
+}
+
+// Begin returns a trace for the frame if any session matched, otherwise
+// nil.
+func (t *Tracer) Begin(client libol.SocketClient, frame *libol.FrameMessage) *frameTrace {
+	if atomic.LoadInt32(&t.active) == 0 || frame.IsControl() {
+		return nil
+	}
+	point, ok := client.Private().(*models.Point)
+	if !ok || point == nil {
+		return nil
+	}
+	f := newCaptureFrame(frame.Data())
+	if f == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var ft *frameTrace
+	for _, s := range t.sessions {
+		if !s.running || !s.Match(point.Network, point.UUID, f) {
+			continue
+		}
+		if ft == nil {
+			ft = &frameTrace{
+				record: schema.TraceRecord{
+					Time:    time.Now().UnixNano(),
+					Point:   point.UUID,
+					Network: point.Network,
+					Frame:   f.String(),
+					Hooks:   make([]schema.TraceHook, 0, len(t.hooks)),
+				},
+			}
+		}
+		ft.sessions = append(ft.sessions, s)
+	}
+	return ft
+}
+
+// End saves the trace into sessions matched.
+func (t *Tracer) End(ft *frameTrace) {
+	if ft == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, s := range ft.sessions {
+		if !s.running {
+			continue
+		}
+		s.cfg.Records = append(s.cfg.Records, ft.record)
+		s.cfg.Traced++
+		if s.cfg.Traced >= s.cfg.Count {
+			t.finish(s)
+		}
+	}
+}
+
+func (t *Tracer) finish(s *traceSession) {
+	if !s.running {
+		return
+	}
+	s.running = false
+	s.timer.Stop()
+	atomic.AddInt32(&t.active, -1)
+	libol.Info("Tracer.finish %s with %d frames", s.cfg.ID, s.cfg.Traced)
+}
+
+func (t *Tracer) Add(cfg schema.Trace) (schema.Trace, error) {
+	if cfg.Count <= 0 {
+		cfg.Count = 100
+	} else if cfg.Count > traceMaxCount {
+		cfg.Count = traceMaxCount
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = 60
+	} else if cfg.Duration > traceMaxDuration {
+		cfg.Duration = traceMaxDuration
+	}
+	terms, err := parseCaptureFilter(cfg.Filter)
+	if err != nil {
+		return cfg, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.sessions) >= traceMaxSessions {
+		return cfg, libol.NewErr("too many traces")
+	}
+	cfg.ID = libol.GenToken(8)
+	cfg.Start = time.Now().Unix()
+	cfg.Traced = 0
+	cfg.Records = make([]schema.TraceRecord, 0, cfg.Count)
+	s := &traceSession{cfg: cfg, terms: terms, running: true}
+	t.sessions[cfg.ID] = s
+	atomic.AddInt32(&t.active, 1)
+	s.timer = time.AfterFunc(time.Duration(cfg.Duration)*time.Second, func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		t.finish(s)
+	})
+	libol.Info("Tracer.Add %s on %s:%s by '%s'", cfg.ID, cfg.Network, cfg.Point, cfg.Filter)
+	return s.Schema(false), nil
+}
+
+func (t *Tracer) List() []schema.Trace {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	list := make([]schema.Trace, 0, len(t.sessions))
+	for _, s := range t.sessions {
+		list = append(list, s.Schema(false))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start < list[j].Start
+	})
+	return list
+}
+
+// Get returns the session with records, or nil if not found.
+func (t *Tracer) Get(id string) *schema.Trace {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if s, ok := t.sessions[id]; ok {
+		c := s.Schema(true)
+		c.Records = append([]schema.TraceRecord{}, c.Records...)
+		return &c
+	}
+	return nil
+}
+
+func (t *Tracer) Del(id string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok {
+		return libol.NewErr("trace %s not found", id)
+	}
+	t.finish(s)
+	delete(t.sessions, id)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/danieldin95/openlan-go/point"
 	"github.com/danieldin95/openlan-go/switch/api"
 	"github.com/danieldin95/openlan-go/switch/storage"
+	"strings"
 	"sync"
 	"time"
 )
@@ -66,6 +67,15 @@ func (w *NetworkWorker) Initialize() {
 				NextHop: rt.NextHop,
 			})
 		}
+		if dns := w.cfg.Dns; dns != nil && dns.Enable && w.cfg.Bridge.Address != "" {
+			ifAddr := strings.SplitN(w.cfg.Bridge.Address, "/", 2)[0]
+			met.Dns = append(met.Dns, ifAddr)
+			met.Search = append(met.Search, w.cfg.Name+"."+dns.Domain)
+		}
+		if rsv := w.cfg.Resolver; rsv != nil {
+			met.Dns = append(met.Dns, rsv.Servers...)
+			met.Search = append(met.Search, rsv.Search...)
+		}
 		storage.Network.Add(&met)
 	}
 }